@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+)
+
+func updateBalloonRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, _ []string) {
+
+	var req requests.UpdateBalloonRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	update, err := configs.NewBalloonUpdateConfigFromRequest(&req)
+	if err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.UpdateBalloon(vmID, update); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func balloonStatsRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, _ []string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	stats, err := fcManager.BalloonStats(vmID)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	writeJSONResponse(w, 200, &response.BalloonStatsResponse{
+		TargetPages:        firecracker.Int64Value(stats.TargetPages),
+		ActualPages:        firecracker.Int64Value(stats.ActualPages),
+		TargetMib:          firecracker.Int64Value(stats.TargetMib),
+		ActualMib:          firecracker.Int64Value(stats.ActualMib),
+		SwapIn:             stats.SwapIn,
+		SwapOut:            stats.SwapOut,
+		MajorFaults:        stats.MajorFaults,
+		MinorFaults:        stats.MinorFaults,
+		FreeMemory:         stats.FreeMemory,
+		TotalMemory:        stats.TotalMemory,
+		AvailableMemory:    stats.AvailableMemory,
+		DiskCaches:         stats.DiskCaches,
+		HugetlbAllocations: stats.HugetlbAllocations,
+		HugetlbFailures:    stats.HugetlbFailures,
+	})
+}
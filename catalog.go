@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"strings"
+	"time"
+)
+
+// catalogRequestHandler returns the handler of the requests made to /v1/{kind} and /v1/{kind}/{ref},
+// where kind is images, kernels or initrds and ref is name:version or a name.
+func catalogRequestHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Add("Content-Type", "application/json")
+
+		ref := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"+kind), "/")
+
+		switch {
+		case ref == "" && r.Method == http.MethodGet:
+			listCatalogRequestHandler(w, r, kind)
+		case ref == "" && r.Method == http.MethodPost:
+			registerCatalogEntryRequestHandler(w, r, kind)
+		case kind == configs.CatalogKindImage && ref == "build" && r.Method == http.MethodPost:
+			buildImageRequestHandler(w, r)
+		case ref != "" && strings.Contains(ref, "/"):
+			writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+		case ref != "" && r.Method == http.MethodGet:
+			getCatalogEntryRequestHandler(w, r, kind, ref)
+		case ref != "" && r.Method == http.MethodDelete:
+			deleteCatalogEntryRequestHandler(w, r, kind, ref)
+		default:
+			writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+		}
+	}
+}
+
+func listCatalogRequestHandler(w http.ResponseWriter, r *http.Request, kind string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	entries, err := fcManager.ListCatalog(kind)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	result := []response.CatalogEntryResponse{}
+	for _, entry := range entries {
+		result = append(result, catalogEntryResponse(entry))
+	}
+
+	switch kind {
+	case configs.CatalogKindKernel:
+		writeJSONResponse(w, 200, &response.ListKernelsResponse{Kernels: result})
+	case configs.CatalogKindInitrd:
+		writeJSONResponse(w, 200, &response.ListInitrdsResponse{Initrds: result})
+	default:
+		writeJSONResponse(w, 200, &response.ListImagesResponse{Images: result})
+	}
+}
+
+func getCatalogEntryRequestHandler(w http.ResponseWriter, r *http.Request, kind, ref string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	entry, err := fcManager.GetCatalogEntry(kind, ref)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := catalogEntryResponse(entry)
+	writeJSONResponse(w, 200, &resp)
+}
+
+// registerCatalogEntryRequestHandler registers a file of the host given by a JSON body,
+// or uploads the file sent as an application/octet-stream body described by the query parameters.
+func registerCatalogEntryRequestHandler(w http.ResponseWriter, r *http.Request, kind string) {
+
+	var req requests.CatalogEntryRequest
+	upload := r.Header.Get("Content-Type") == "application/octet-stream"
+
+	if upload {
+		query := r.URL.Query()
+		req = requests.CatalogEntryRequest{
+			Name:        query.Get("name"),
+			Version:     query.Get("version"),
+			Arch:        query.Get("arch"),
+			Description: query.Get("description"),
+		}
+	} else {
+		if err := readJSONBody(r, &req); err != nil {
+			writeErrorResponse(w, 422, err.Error())
+			return
+		}
+		if req.Path == "" {
+			writeErrorResponse(w, 422, "path is required, or upload the file as application/octet-stream")
+			return
+		}
+	}
+
+	entryConfig := configs.NewCatalogEntryConfigFromRequest(kind, &req)
+	if err := entryConfig.Validate(); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	entry, err := fcManager.RegisterCatalogEntry(entryConfig, r.Body)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := catalogEntryResponse(entry)
+	writeJSONResponse(w, 201, &resp)
+}
+
+func buildImageRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	var req requests.BuildImageRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	buildConfig := configs.NewImageBuildConfigFromRequest(&req)
+	if err := buildConfig.Validate(); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	entry, err := fcManager.BuildImage(buildConfig)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := catalogEntryResponse(entry)
+	writeJSONResponse(w, 201, &resp)
+}
+
+func deleteCatalogEntryRequestHandler(w http.ResponseWriter, r *http.Request, kind, ref string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.DeleteCatalogEntry(kind, ref); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func catalogEntryResponse(entry *managers.CatalogEntryInfo) response.CatalogEntryResponse {
+	return response.CatalogEntryResponse{
+		ID:          entry.ID(),
+		Name:        entry.Name,
+		Version:     entry.Version,
+		Arch:        entry.Arch,
+		Description: entry.Description,
+		Path:        entry.Path,
+		SHA256:      entry.SHA256,
+		SizeBytes:   entry.SizeBytes,
+		Uploaded:    entry.Uploaded,
+		CreatedAt:   entry.CreatedAt.Format(time.RFC3339),
+		UsedBy:      entry.UsedBy,
+	}
+}
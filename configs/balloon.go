@@ -0,0 +1,62 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+)
+
+// BalloonConfig provides memory balloon device configuration options.
+type BalloonConfig struct {
+	AmountMib             int64 `json:"AmountMib" mapstructure:"AmountMib" description:"Target size of the balloon in MiB"`
+	DeflateOnOom          bool  `json:"DeflateOnOom" mapstructure:"DeflateOnOom" description:"If the balloon should deflate when the guest is out of memory"`
+	StatsPollingIntervalS int64 `json:"StatsPollingIntervalS" mapstructure:"StatsPollingIntervalS" description:"Interval in seconds between balloon statistics refreshes, 0 disables the statistics"`
+}
+
+// NewBalloonConfigFromRequest returns a balloon configuration for the request, nil if no balloon was requested.
+func NewBalloonConfigFromRequest(balloon *requests.BalloonRequest) *BalloonConfig {
+	if balloon == nil {
+		return nil
+	}
+	return &BalloonConfig{
+		AmountMib:             balloon.AmountMib,
+		DeflateOnOom:          balloon.DeflateOnOom,
+		StatsPollingIntervalS: balloon.StatsPollingIntervalS,
+	}
+}
+
+// Validate validates the balloon against the memory size of the machine.
+func (c *BalloonConfig) Validate(memSizeMib int64) error {
+	if c.AmountMib < 0 {
+		return fmt.Errorf("balloon amountMib cannot be negative")
+	}
+	if c.AmountMib >= memSizeMib {
+		return fmt.Errorf("balloon amountMib must be lower than memSizeMib (%d)", memSizeMib)
+	}
+	if c.StatsPollingIntervalS < 0 {
+		return fmt.Errorf("balloon statsPollingIntervalS cannot be negative")
+	}
+	return nil
+}
+
+// BalloonUpdateConfig represents a runtime update of the balloon device.
+type BalloonUpdateConfig struct {
+	AmountMib             *int64
+	StatsPollingIntervalS *int64
+}
+
+// NewBalloonUpdateConfigFromRequest validates and returns a balloon update for the request.
+func NewBalloonUpdateConfigFromRequest(update *requests.UpdateBalloonRequest) (*BalloonUpdateConfig, error) {
+	if update.AmountMib == nil && update.StatsPollingIntervalS == nil {
+		return nil, fmt.Errorf("at least one of amountMib or statsPollingIntervalS is required")
+	}
+	if update.AmountMib != nil && *update.AmountMib < 0 {
+		return nil, fmt.Errorf("balloon amountMib cannot be negative")
+	}
+	if update.StatsPollingIntervalS != nil && *update.StatsPollingIntervalS < 0 {
+		return nil, fmt.Errorf("balloon statsPollingIntervalS cannot be negative")
+	}
+	return &BalloonUpdateConfig{
+		AmountMib:             update.AmountMib,
+		StatsPollingIntervalS: update.StatsPollingIntervalS,
+	}, nil
+}
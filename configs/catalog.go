@@ -0,0 +1,107 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+	"path/filepath"
+	"regexp"
+	"runtime"
+)
+
+const (
+	// CatalogKindImage is the catalog of root filesystems.
+	CatalogKindImage = "images"
+	// CatalogKindKernel is the catalog of kernels.
+	CatalogKindKernel = "kernels"
+	// CatalogKindInitrd is the catalog of initrds.
+	CatalogKindInitrd = "initrds"
+)
+
+// catalogNameRegexp matches the names and versions of the catalog entries, references have the form name:version.
+var catalogNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}$`)
+
+// CatalogConfig provides the image, kernel and initrd catalog configuration options.
+type CatalogConfig struct {
+	Dir        string `json:"Dir" mapstructure:"Dir" description:"Directory of the catalog indexes and uploaded files, CATALOG_DIR"`
+	GuestAgent string `json:"GuestAgent" mapstructure:"GuestAgent" description:"Guest agent binary injected in the images built from archives, GUEST_AGENT_PATH, none if empty"`
+}
+
+// NewCatalogConfig returns a new instance of the configuration, read from the environment.
+func NewCatalogConfig() *CatalogConfig {
+	return &CatalogConfig{
+		Dir:        utils.GetenvOrDefault("CATALOG_DIR", "/srv/catalog"),
+		GuestAgent: utils.GetenvOrDefault("GUEST_AGENT_PATH", ""),
+	}
+}
+
+// IndexPath returns the path of the index of a catalog.
+func (c *CatalogConfig) IndexPath(kind string) string {
+	return filepath.Join(c.Dir, kind+".json")
+}
+
+// UploadDir returns the directory the uploaded files of a catalog are stored in.
+func (c *CatalogConfig) UploadDir(kind string) string {
+	return filepath.Join(c.Dir, kind)
+}
+
+// CatalogEntryConfig provides the registration of a kernel, an initrd or a root filesystem in the catalog.
+type CatalogEntryConfig struct {
+	Kind        string `json:"Kind" mapstructure:"Kind" description:"images, kernels or initrds"`
+	Name        string `json:"Name" mapstructure:"Name" description:"Name referenced by the create requests"`
+	Version     string `json:"Version" mapstructure:"Version" description:"Optional version, referenced as name:version"`
+	Path        string `json:"Path" mapstructure:"Path" description:"Path of the file on the host, empty for uploads"`
+	Arch        string `json:"Arch" mapstructure:"Arch" description:"x86_64 or aarch64, the host architecture by default"`
+	Description string `json:"Description" mapstructure:"Description" description:"Free form description"`
+}
+
+// NewCatalogEntryConfigFromRequest returns the registration of the request, for the host architecture by default.
+func NewCatalogEntryConfigFromRequest(kind string, entry *requests.CatalogEntryRequest) *CatalogEntryConfig {
+	arch := entry.Arch
+	if arch == "" {
+		arch = HostArch()
+	}
+	return &CatalogEntryConfig{
+		Kind:        kind,
+		Name:        entry.Name,
+		Version:     entry.Version,
+		Path:        entry.Path,
+		Arch:        arch,
+		Description: entry.Description,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *CatalogEntryConfig) Validate() error {
+	if c.Kind != CatalogKindImage && c.Kind != CatalogKindKernel && c.Kind != CatalogKindInitrd {
+		return fmt.Errorf("catalog must be %s, %s or %s", CatalogKindImage, CatalogKindKernel, CatalogKindInitrd)
+	}
+	if !catalogNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("name must be up to 64 letters, digits, '.', '_' or '-', starting with a letter or a digit")
+	}
+	if c.Version != "" && !catalogNameRegexp.MatchString(c.Version) {
+		return fmt.Errorf("version must be up to 64 letters, digits, '.', '_' or '-', starting with a letter or a digit")
+	}
+	if c.Path != "" && !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path must be absolute")
+	}
+	if c.Arch != "x86_64" && c.Arch != "aarch64" {
+		return fmt.Errorf("arch must be x86_64 or aarch64")
+	}
+	if len(c.Description) > 1024 {
+		return fmt.Errorf("description cannot be longer than 1024 characters")
+	}
+	return nil
+}
+
+// HostArch returns the architecture of the host, named like uname -m.
+func HostArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	default:
+		return runtime.GOARCH
+	}
+}
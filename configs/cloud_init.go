@@ -0,0 +1,99 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"open-fire/dtos/requests"
+	"strings"
+)
+
+const (
+	// CloudInitDatasourceMMDS delivers the user data and the keys in the EC2 layout of MMDS, the default.
+	CloudInitDatasourceMMDS = "mmds"
+	// CloudInitDatasourceNoCloud delivers them on a NoCloud seed drive attached read-only.
+	CloudInitDatasourceNoCloud = "nocloud"
+)
+
+// SeedDriveID is the drive ID of the NoCloud seed drive.
+const SeedDriveID = "cidata"
+
+// UserDataMaxSizeBytes is the maximum size of the user data, the EC2 limit.
+const UserDataMaxSizeBytes = 16384
+
+// sshKeyTypes are the prefixes of the supported OpenSSH public keys.
+var sshKeyTypes = []string{"ssh-", "ecdsa-", "sk-"}
+
+// CloudInitConfig provides the cloud-init user data and SSH authorized keys of a VM.
+type CloudInitConfig struct {
+	UserData          string   `json:"UserData" mapstructure:"UserData" description:"cloud-config or script run by cloud-init"`
+	SSHAuthorizedKeys []string `json:"SSHAuthorizedKeys" mapstructure:"SSHAuthorizedKeys" description:"OpenSSH public keys authorized in the guest"`
+	Datasource        string   `json:"Datasource" mapstructure:"Datasource" description:"mmds or nocloud"`
+}
+
+// NewCloudInitConfigFromRequest returns the cloud-init configuration of the request, delivered through MMDS by default.
+func NewCloudInitConfigFromRequest(createVM *requests.CreateVMRequest) *CloudInitConfig {
+	datasource := strings.ToLower(createVM.CloudInitDatasource)
+	if datasource == "" {
+		datasource = CloudInitDatasourceMMDS
+	}
+	keys := []string{}
+	for _, key := range createVM.SSHAuthorizedKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return &CloudInitConfig{
+		UserData:          createVM.UserData,
+		SSHAuthorizedKeys: keys,
+		Datasource:        datasource,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *CloudInitConfig) Validate() error {
+	if c.Datasource != CloudInitDatasourceMMDS && c.Datasource != CloudInitDatasourceNoCloud {
+		return fmt.Errorf("cloudInitDatasource must be %s or %s", CloudInitDatasourceMMDS, CloudInitDatasourceNoCloud)
+	}
+	if len(c.UserData) > UserDataMaxSizeBytes {
+		return fmt.Errorf("userData cannot be larger than %d bytes", UserDataMaxSizeBytes)
+	}
+	for i, key := range c.SSHAuthorizedKeys {
+		if strings.ContainsAny(key, "\r\n") || !hasSSHKeyType(key) || len(strings.Fields(key)) < 2 {
+			return fmt.Errorf("sshAuthorizedKeys %d is not an OpenSSH public key", i+1)
+		}
+	}
+	return nil
+}
+
+// Enabled returns true if there is something to deliver to cloud-init.
+func (c *CloudInitConfig) Enabled() bool {
+	return c != nil && (c.UserData != "" || len(c.SSHAuthorizedKeys) > 0)
+}
+
+// InMMDS returns true if the user data and the keys are delivered through MMDS.
+func (c *CloudInitConfig) InMMDS() bool {
+	return c.Enabled() && c.Datasource == CloudInitDatasourceMMDS
+}
+
+// OnSeedDrive returns true if the user data and the keys are delivered on a NoCloud seed drive.
+func (c *CloudInitConfig) OnSeedDrive() bool {
+	return c.Enabled() && c.Datasource == CloudInitDatasourceNoCloud
+}
+
+// NoCloudMetaData returns the NoCloud meta-data file. cloud-init reads it as YAML, which JSON is a subset of.
+func (c *CloudInitConfig) NoCloudMetaData(instanceID, hostname string) ([]byte, error) {
+	return json.MarshalIndent(map[string]interface{}{
+		"instance-id":    instanceID,
+		"local-hostname": hostname,
+		"public-keys":    c.SSHAuthorizedKeys,
+	}, "", "  ")
+}
+
+func hasSSHKeyType(key string) bool {
+	for _, keyType := range sshKeyTypes {
+		if strings.HasPrefix(key, keyType) {
+			return true
+		}
+	}
+	return false
+}
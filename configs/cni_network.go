@@ -0,0 +1,138 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"open-fire/dtos/requests"
+	"regexp"
+)
+
+// CNINetworkCNIVersion is the CNI version of the created networks, the one of host_setup/open-fire.conflist.
+const CNINetworkCNIVersion = "0.4.0"
+
+var cniNetworkNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// CNINetworkConfig provides the configuration of a bridge network created from the open-fire template.
+type CNINetworkConfig struct {
+	Name       string `json:"Name" mapstructure:"Name" description:"Name of the CNI network, also the name of the conflist file"`
+	Bridge     string `json:"Bridge" mapstructure:"Bridge" description:"Name of the bridge device on the host"`
+	Subnet     string `json:"Subnet" mapstructure:"Subnet" description:"Subnet the IPs of the VMs are allocated from"`
+	IPv6Subnet string `json:"IPv6Subnet" mapstructure:"IPv6Subnet" description:"IPv6 subnet making the network dual-stack, optional"`
+	Masquerade bool   `json:"Masquerade" mapstructure:"Masquerade" description:"If the traffic leaving the subnet is masqueraded"`
+	Firewall   bool   `json:"Firewall" mapstructure:"Firewall" description:"If the firewall plugin is part of the chain"`
+}
+
+// NewCNINetworkConfigFromRequest returns the network configuration of the request.
+// The bridge defaults to of-<name>, masquerade and firewall default to true.
+func NewCNINetworkConfigFromRequest(createNetwork *requests.CreateNetworkRequest) *CNINetworkConfig {
+	bridge := createNetwork.Bridge
+	if bridge == "" {
+		bridge = "of-" + createNetwork.Name
+	}
+	masquerade := true
+	if createNetwork.Masquerade != nil {
+		masquerade = *createNetwork.Masquerade
+	}
+	firewall := true
+	if createNetwork.Firewall != nil {
+		firewall = *createNetwork.Firewall
+	}
+	return &CNINetworkConfig{
+		Name:       createNetwork.Name,
+		Bridge:     bridge,
+		Subnet:     createNetwork.Subnet,
+		IPv6Subnet: createNetwork.IPv6Subnet,
+		Masquerade: masquerade,
+		Firewall:   firewall,
+	}
+}
+
+// Validate validates the correctness of the configuration and normalizes the subnet.
+func (c *CNINetworkConfig) Validate() error {
+	if !cniNetworkNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("name must be 1 to 64 lowercase letters, digits, '-' or '_', starting with a letter or a digit")
+	}
+	if len(c.Bridge) > IfNameMaxLength {
+		return fmt.Errorf("bridge name %s is longer than %d characters", c.Bridge, IfNameMaxLength)
+	}
+	_, subnet, err := net.ParseCIDR(c.Subnet)
+	if err != nil {
+		return fmt.Errorf("subnet is invalid: %s", err)
+	}
+	if subnet.IP.To4() == nil {
+		return fmt.Errorf("subnet must be an IPv4 network")
+	}
+	if ones, _ := subnet.Mask.Size(); ones > 29 {
+		return fmt.Errorf("subnet is too small, the prefix length cannot be greater than 29")
+	}
+	c.Subnet = subnet.String()
+
+	if c.IPv6Subnet != "" {
+		_, ipv6Subnet, err := net.ParseCIDR(c.IPv6Subnet)
+		if err != nil {
+			return fmt.Errorf("ipv6Subnet is invalid: %s", err)
+		}
+		if ipv6Subnet.IP.To4() != nil {
+			return fmt.Errorf("ipv6Subnet must be an IPv6 network")
+		}
+		if ones, _ := ipv6Subnet.Mask.Size(); ones > 125 {
+			return fmt.Errorf("ipv6Subnet is too small, the prefix length cannot be greater than 125")
+		}
+		c.IPv6Subnet = ipv6Subnet.String()
+	}
+	return nil
+}
+
+// Subnets returns the subnet and, for dual-stack networks, the IPv6 subnet.
+func (c *CNINetworkConfig) Subnets() []string {
+	if c.IPv6Subnet == "" {
+		return []string{c.Subnet}
+	}
+	return []string{c.Subnet, c.IPv6Subnet}
+}
+
+// ConfList renders the conflist of the network, following host_setup/open-fire.conflist.
+// Dual-stack networks use one host-local range set per family.
+func (c *CNINetworkConfig) ConfList() ([]byte, error) {
+	ipam := map[string]interface{}{
+		"type":       "host-local",
+		"resolvConf": "/etc/resolv.conf",
+	}
+	if c.IPv6Subnet == "" {
+		ipam["subnet"] = c.Subnet
+	} else {
+		ipam["ranges"] = [][]map[string]string{
+			{{"subnet": c.Subnet}},
+			{{"subnet": c.IPv6Subnet}},
+		}
+	}
+
+	plugins := []map[string]interface{}{
+		{
+			"type":             "bridge",
+			"name":             c.Name + "-bridge",
+			"bridge":           c.Bridge,
+			"isDefaultGateway": true,
+			"ipMasq":           c.Masquerade,
+			"hairpinMode":      true,
+			"ipam":             ipam,
+		},
+	}
+	if c.Firewall {
+		plugins = append(plugins, map[string]interface{}{"type": "firewall"})
+	}
+	plugins = append(plugins,
+		map[string]interface{}{
+			"type":         "portmap",
+			"capabilities": map[string]bool{"portMappings": true},
+		},
+		map[string]interface{}{"type": "tc-redirect-tap"},
+	)
+
+	return json.MarshalIndent(map[string]interface{}{
+		"name":       c.Name,
+		"cniVersion": CNINetworkCNIVersion,
+		"plugins":    plugins,
+	}, "", "    ")
+}
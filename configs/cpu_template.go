@@ -0,0 +1,152 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// staticCPUTemplates lists the static CPU templates of Firecracker by host architecture.
+var staticCPUTemplates = map[string][]string{
+	"x86_64":  {"C3", "T2", "T2S", "T2CL", "T2A"},
+	"aarch64": {"V1N1"},
+}
+
+// cpuTemplateNameRegexp matches the names of the custom CPU templates, the file names of the templates directory without .json.
+var cpuTemplateNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}$`)
+
+// CPUTemplatesConfig provides the custom CPU templates configuration options.
+type CPUTemplatesConfig struct {
+	Dir    string `json:"Dir" mapstructure:"Dir" description:"Directory of the custom CPU template files, CPU_TEMPLATES_DIR"`
+	Helper string `json:"Helper" mapstructure:"Helper" description:"Path to the cpu-template-helper binary of the Firecracker release, CPU_TEMPLATE_HELPER_PATH"`
+}
+
+// NewCPUTemplatesConfig returns a new instance of the configuration, read from the environment.
+func NewCPUTemplatesConfig() *CPUTemplatesConfig {
+	return &CPUTemplatesConfig{
+		Dir:    utils.GetenvOrDefault("CPU_TEMPLATES_DIR", "/srv/cpu-templates"),
+		Helper: utils.GetenvOrDefault("CPU_TEMPLATE_HELPER_PATH", "/usr/bin/cpu-template-helper"),
+	}
+}
+
+// TemplatePath returns the path of the custom CPU template with the given name.
+func (c *CPUTemplatesConfig) TemplatePath(name string) (string, error) {
+	if err := ValidateCustomCPUTemplateName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, name+".json"), nil
+}
+
+// StaticCPUTemplates returns the static CPU templates available on the host architecture.
+func StaticCPUTemplates() []string {
+	return append([]string{}, staticCPUTemplates[HostArch()]...)
+}
+
+// IsStaticCPUTemplate returns true if the name is a static CPU template of any architecture, in any case.
+func IsStaticCPUTemplate(name string) bool {
+	return staticCPUTemplate(name) != ""
+}
+
+// ValidateCustomCPUTemplateName returns an error if the name cannot be used for a custom CPU template.
+func ValidateCustomCPUTemplateName(name string) error {
+	if !cpuTemplateNameRegexp.MatchString(name) {
+		return fmt.Errorf("CPU template name must be up to 64 letters, digits, '.', '_' or '-', starting with a letter or a digit")
+	}
+	if IsStaticCPUTemplate(name) {
+		return fmt.Errorf("CPU template name %s is the name of a static template", name)
+	}
+	return nil
+}
+
+// validateStaticCPUTemplate returns an error if the static CPU template is not available on the host architecture.
+func validateStaticCPUTemplate(name string) error {
+	for _, template := range staticCPUTemplates[HostArch()] {
+		if template == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("CPU template %s is not available on %s, use one of %s or a custom template",
+		name, HostArch(), strings.Join(staticCPUTemplates[HostArch()], ", "))
+}
+
+// staticCPUTemplate returns the Firecracker name of a static CPU template, empty if the name is not one.
+func staticCPUTemplate(name string) string {
+	for _, templates := range staticCPUTemplates {
+		for _, template := range templates {
+			if strings.EqualFold(template, name) {
+				return template
+			}
+		}
+	}
+	return ""
+}
+
+// CPUTemplateHelperConfig provides the VM cpu-template-helper boots to dump or verify a CPU template.
+type CPUTemplateHelperConfig struct {
+	KernelPath    string `json:"KernelPath" mapstructure:"KernelPath" description:"Path of the kernel of the helper VM"`
+	RootDrivePath string `json:"RootDrivePath" mapstructure:"RootDrivePath" description:"Path of the root filesystem of the helper VM, attached read-only"`
+	VcpuCount     int64  `json:"VcpuCount" mapstructure:"VcpuCount" description:"vCPUs of the helper VM, 2 if 0"`
+	MemSizeMib    int64  `json:"MemSizeMib" mapstructure:"MemSizeMib" description:"Memory of the helper VM, 256 if 0"`
+}
+
+// NewCPUTemplateHelperConfigFromRequest returns the helper VM of the request, with its defaults.
+func NewCPUTemplateHelperConfigFromRequest(helper *requests.CPUTemplateHelperRequest) *CPUTemplateHelperConfig {
+	c := &CPUTemplateHelperConfig{
+		KernelPath:    helper.KernelPath,
+		RootDrivePath: helper.RootDrivePath,
+		VcpuCount:     helper.VcpuCount,
+		MemSizeMib:    helper.MemSizeMib,
+	}
+	if c.VcpuCount == 0 {
+		c.VcpuCount = 2
+	}
+	if c.MemSizeMib == 0 {
+		c.MemSizeMib = 256
+	}
+	return c
+}
+
+// Validate validates the correctness of the configuration.
+func (c *CPUTemplateHelperConfig) Validate() error {
+	if c.KernelPath == "" || !filepath.IsAbs(c.KernelPath) {
+		return fmt.Errorf("kernelPath must be an absolute path")
+	}
+	if c.RootDrivePath == "" || !filepath.IsAbs(c.RootDrivePath) {
+		return fmt.Errorf("rootDrivePath must be an absolute path")
+	}
+	if c.VcpuCount < 1 || c.VcpuCount > 32 {
+		return fmt.Errorf("vCpuCount must be between 1 and 32")
+	}
+	if c.MemSizeMib < 128 {
+		return fmt.Errorf("memSizeMib cannot be lower than 128")
+	}
+	return nil
+}
+
+// FirecrackerConfig returns the Firecracker configuration file of the helper VM, with the custom CPU template
+// to verify if the path is not empty.
+func (c *CPUTemplateHelperConfig) FirecrackerConfig(cpuConfigPath string) map[string]interface{} {
+	config := map[string]interface{}{
+		"boot-source": map[string]interface{}{
+			"kernel_image_path": c.KernelPath,
+			"boot_args":         "console=ttyS0 reboot=k panic=1 pci=off",
+		},
+		"drives": []map[string]interface{}{{
+			"drive_id":       RootDriveID,
+			"path_on_host":   c.RootDrivePath,
+			"is_root_device": true,
+			"is_read_only":   true,
+		}},
+		"machine-config": map[string]interface{}{
+			"vcpu_count":   c.VcpuCount,
+			"mem_size_mib": c.MemSizeMib,
+		},
+	}
+	if cpuConfigPath != "" {
+		config["cpu-config"] = cpuConfigPath
+	}
+	return config
+}
@@ -0,0 +1,38 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/utils"
+	"path/filepath"
+	"regexp"
+)
+
+// diskNameRegexp matches the disk names, the file names of the disks directory.
+var diskNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,127}$`)
+
+// DisksConfig provides the host disk inspection configuration options.
+type DisksConfig struct {
+	Dir      string `json:"Dir" mapstructure:"Dir" description:"Directory of the rootfs and data disk images, DISKS_DIR"`
+	MountDir string `json:"MountDir" mapstructure:"MountDir" description:"Directory the disks are mounted in for inspection, DISKS_MOUNT_DIR"`
+}
+
+// NewDisksConfig returns a new instance of the configuration, read from the environment.
+func NewDisksConfig() *DisksConfig {
+	return &DisksConfig{
+		Dir:      utils.GetenvOrDefault("DISKS_DIR", "/srv/disks"),
+		MountDir: utils.GetenvOrDefault("DISKS_MOUNT_DIR", "/var/lib/open-fire/mounts"),
+	}
+}
+
+// DiskPath returns the path of the disk image with the given name.
+func (c *DisksConfig) DiskPath(name string) (string, error) {
+	if !diskNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("disk name %q must be a file name of letters, digits, '.', '_' or '-'", name)
+	}
+	return filepath.Join(c.Dir, name), nil
+}
+
+// DiskMountDir returns the directory the disk with the given name is mounted at.
+func (c *DisksConfig) DiskMountDir(name string) string {
+	return filepath.Join(c.MountDir, name)
+}
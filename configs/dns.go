@@ -0,0 +1,91 @@
+package configs
+
+import (
+	"bufio"
+	"net"
+	"open-fire/utils"
+	"os"
+	"strings"
+)
+
+// DNSConfig provides the embedded DNS resolver configuration options.
+type DNSConfig struct {
+	Enabled    bool     `json:"Enabled" mapstructure:"Enabled" description:"If the resolver runs on the bridge gateways and is pushed to the guests, DNS_ENABLED"`
+	Domain     string   `json:"Domain" mapstructure:"Domain" description:"Domain of the VM names, resolved as <vm>.<network>.<domain>"`
+	Port       int      `json:"Port" mapstructure:"Port" description:"UDP port of the resolver, guests only query port 53"`
+	TTL        uint32   `json:"TTL" mapstructure:"TTL" description:"TTL in seconds of the VM records"`
+	Upstreams  []string `json:"Upstreams" mapstructure:"Upstreams" description:"Upstream resolvers as host:port, DNS_UPSTREAMS, the resolv.conf nameservers if empty"`
+	ResolvConf string   `json:"ResolvConf" mapstructure:"ResolvConf" description:"Host resolv.conf the default upstreams are read from"`
+
+	ForwardNetworks      []string `json:"ForwardNetworks" mapstructure:"ForwardNetworks" description:"Networks whose queries outside of the domain are forwarded upstream, DNS_FORWARD_NETWORKS, none if empty"`
+	MaxConcurrentQueries int      `json:"MaxConcurrentQueries" mapstructure:"MaxConcurrentQueries" description:"Queries answered at the same time, the next ones wait"`
+}
+
+// NewDNSConfig returns a new instance of the configuration, read from the environment.
+func NewDNSConfig() *DNSConfig {
+	upstreams := []string{}
+	for _, upstream := range strings.Split(utils.GetenvOrDefault("DNS_UPSTREAMS", ""), ",") {
+		if upstream = strings.TrimSpace(upstream); upstream != "" {
+			upstreams = append(upstreams, withDNSPort(upstream))
+		}
+	}
+	forwardNetworks := []string{}
+	for _, network := range strings.Split(utils.GetenvOrDefault("DNS_FORWARD_NETWORKS", ""), ",") {
+		if network = strings.TrimSpace(network); network != "" {
+			forwardNetworks = append(forwardNetworks, network)
+		}
+	}
+	return &DNSConfig{
+		Enabled:    utils.GetenvOrDefault("DNS_ENABLED", "true") == "true",
+		Domain:     "internal",
+		Port:       53,
+		TTL:        5,
+		Upstreams:  upstreams,
+		ResolvConf: "/etc/resolv.conf",
+
+		ForwardNetworks:      forwardNetworks,
+		MaxConcurrentQueries: 64,
+	}
+}
+
+// Forwards returns true if the queries of the guests of the network outside of the domain are forwarded upstream.
+func (c *DNSConfig) Forwards(network string) bool {
+	for _, forwardNetwork := range c.ForwardNetworks {
+		if forwardNetwork == network {
+			return true
+		}
+	}
+	return false
+}
+
+// UpstreamServers returns the configured upstreams, else the nameservers of the host resolv.conf.
+// The resolver runs on the host, so the loopback stub resolvers unreachable from the guests work here.
+func (c *DNSConfig) UpstreamServers() []string {
+	if len(c.Upstreams) > 0 {
+		return c.Upstreams
+	}
+	result := []string{}
+	file, err := os.Open(c.ResolvConf)
+	if err != nil {
+		return []string{"1.1.1.1:53"}
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			result = append(result, withDNSPort(fields[1]))
+		}
+	}
+	if len(result) == 0 {
+		return []string{"1.1.1.1:53"}
+	}
+	return result
+}
+
+func withDNSPort(server string) string {
+	if net.ParseIP(server) != nil {
+		return net.JoinHostPort(server, "53")
+	}
+	return server
+}
@@ -0,0 +1,156 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+)
+
+const (
+	// RootDriveID is the drive ID of the root drive, additional drives are numbered from 2 by default.
+	RootDriveID = "1"
+	// RootfsMaxSizeMib is the maximum size the root filesystem of a VM can be grown to.
+	RootfsMaxSizeMib = 1 << 20
+)
+
+// driveIDRegexp matches the drive IDs, which are also used in the API paths.
+var driveIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// DriveConfig provides the configuration of an additional drive.
+type DriveConfig struct {
+	Path        string             `json:"Path" mapstructure:"Path" description:"Path of the drive backing file on the host"`
+	Volume      string             `json:"Volume" mapstructure:"Volume" description:"Name of the volume backing the drive, if any"`
+	ReadOnly    bool               `json:"ReadOnly" mapstructure:"ReadOnly" description:"If the drive is attached read-only"`
+	DriveID     string             `json:"DriveID" mapstructure:"DriveID" description:"Firecracker drive ID, defaults to the position of the drive starting at 2"`
+	Partuuid    string             `json:"Partuuid" mapstructure:"Partuuid" description:"Unique ID of the boot partition, only used by root drives"`
+	CacheType   string             `json:"CacheType" mapstructure:"CacheType" description:"Unsafe (default) or Writeback"`
+	IoEngine    string             `json:"IoEngine" mapstructure:"IoEngine" description:"Sync (default) or Async"`
+	RateLimiter *RateLimiterConfig `json:"RateLimiter" mapstructure:"RateLimiter" description:"Rate limiter of the drive"`
+}
+
+// NewDriveConfigsFromRequest returns the additional drives of the request, with their default drive IDs.
+func NewDriveConfigsFromRequest(drives []requests.DriveRequest) []*DriveConfig {
+	result := []*DriveConfig{}
+	for i, drive := range drives {
+		driveID := drive.DriveID
+		if driveID == "" {
+			driveID = strconv.Itoa(i + 2)
+		}
+		result = append(result, &DriveConfig{
+			Path:        drive.Path,
+			Volume:      drive.Volume,
+			ReadOnly:    drive.ReadOnly,
+			DriveID:     driveID,
+			Partuuid:    drive.Partuuid,
+			CacheType:   drive.CacheType,
+			IoEngine:    drive.IoEngine,
+			RateLimiter: NewRateLimiterConfigFromRequest(drive.RateLimiter),
+		})
+	}
+	return result
+}
+
+// Validate validates the correctness of the configuration.
+func (c *DriveConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("drive %s must have a path", c.DriveID)
+	}
+	if !driveIDRegexp.MatchString(c.DriveID) {
+		return fmt.Errorf("drive ID %q must be up to 64 letters, digits, '_' or '-'", c.DriveID)
+	}
+	if c.DriveID == RootDriveID {
+		return fmt.Errorf("drive ID %s is reserved for the root drive", RootDriveID)
+	}
+	if c.CacheType != "" && c.CacheType != models.DriveCacheTypeUnsafe && c.CacheType != models.DriveCacheTypeWriteback {
+		return fmt.Errorf("drive %s cacheType must be %s or %s", c.DriveID, models.DriveCacheTypeUnsafe, models.DriveCacheTypeWriteback)
+	}
+	if c.IoEngine != "" && c.IoEngine != models.DriveIoEngineSync && c.IoEngine != models.DriveIoEngineAsync {
+		return fmt.Errorf("drive %s ioEngine must be %s or %s", c.DriveID, models.DriveIoEngineSync, models.DriveIoEngineAsync)
+	}
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Validate(); err != nil {
+			return fmt.Errorf("invalid rate limiter for drive %s: %s", c.DriveID, err)
+		}
+	}
+	return nil
+}
+
+// validateDrives validates the additional drives and the uniqueness of their IDs.
+// The files are hard linked in the jail under their file name, so the drives cannot share a file name with each other
+// or with the other files of the jail, given by file name with what uses them.
+func validateDrives(drives []*DriveConfig, driveRateLimiters map[string]*RateLimiterConfig, jailFiles map[string]string) error {
+	seen := map[string]bool{}
+	fileNames := map[string]string{}
+	for fileName, usedBy := range jailFiles {
+		fileNames[fileName] = usedBy
+	}
+	for _, drive := range drives {
+		if err := drive.Validate(); err != nil {
+			return err
+		}
+		if seen[drive.DriveID] {
+			return fmt.Errorf("drive ID %s is used by several drives", drive.DriveID)
+		}
+		seen[drive.DriveID] = true
+		fileName := filepath.Base(drive.Path)
+		if usedBy, ok := fileNames[fileName]; ok {
+			return fmt.Errorf("drive %s has the file name %s of %s, the files are linked in the jail under their file name", drive.DriveID, fileName, usedBy)
+		}
+		fileNames[fileName] = "drive " + drive.DriveID
+		if _, ok := driveRateLimiters[drive.DriveID]; ok && drive.RateLimiter != nil {
+			return fmt.Errorf("drive %s has a rate limiter in both additionalDrives and driveRateLimiters", drive.DriveID)
+		}
+	}
+	return nil
+}
+
+// DrivePaths returns the host paths of the drives of the machine, the root drive first if there is one.
+func (c *MachineConfig) DrivePaths() []string {
+	result := []string{}
+	if c.HasRootDrive() {
+		result = append(result, c.RootDrivePath())
+	}
+	for _, drive := range c.FcAdditionalDrives {
+		result = append(result, drive.Path)
+	}
+	return result
+}
+
+// jailFiles returns the file names, with what uses them, of the files linked in the jail besides the additional drives.
+// A writable root drive is a clone named after the VM, only a read-only one is linked under its own name.
+func (c *MachineConfig) jailFiles() map[string]string {
+	result := map[string]string{
+		filepath.Base(c.KernelPath): "the kernel",
+	}
+	if c.InitrdPath != "" {
+		result[filepath.Base(c.InitrdPath)] = "the initrd"
+	}
+	if c.HasRootDrive() && c.RootDriveReadOnly() {
+		result[filepath.Base(c.RootDrivePath())] = "the root drive"
+	}
+	return result
+}
+
+// HasRootDrive returns false for the machines booting from their initrd only.
+func (c *MachineConfig) HasRootDrive() bool {
+	return c.RootFSPath != ""
+}
+
+// RootDrivePath returns the host path of the root drive, the clone of the root filesystem if there is one.
+func (c *MachineConfig) RootDrivePath() string {
+	if c.FcRootFSClone != "" {
+		return c.FcRootFSClone
+	}
+	rootDrivePath, _ := parseDevice(c.RootFSPath)
+	return rootDrivePath
+}
+
+// RootDriveReadOnly returns true if the root drive is attached read-only, it is then shared and not cloned.
+func (c *MachineConfig) RootDriveReadOnly() bool {
+	_, readOnly := parseDevice(c.RootFSPath)
+	return readOnly
+}
@@ -0,0 +1,108 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"open-fire/dtos/requests"
+	"strings"
+)
+
+const (
+	// EgressPolicyAllowAll lets the VM reach anything, the default.
+	EgressPolicyAllowAll = "allow-all"
+	// EgressPolicyDenyAll drops all the traffic initiated by the VM.
+	EgressPolicyDenyAll = "deny-all"
+	// EgressPolicyDNSOnly only lets DNS queries out of the VM.
+	EgressPolicyDNSOnly = "dns-only"
+	// EgressPolicyAllowlist only lets out the traffic matching one of the rules.
+	EgressPolicyAllowlist = "allowlist"
+)
+
+// EgressRuleMaxPorts is the maximum number of ports of a rule, the limit of the iptables multiport match.
+const EgressRuleMaxPorts = 15
+
+// EgressPolicyConfig provides the egress network policy of a VM.
+type EgressPolicyConfig struct {
+	Mode  string              `json:"Mode" mapstructure:"Mode" description:"allow-all, deny-all, dns-only or allowlist"`
+	Rules []*EgressRuleConfig `json:"Rules" mapstructure:"Rules" description:"Allowed destinations, only for the allowlist mode"`
+}
+
+// EgressRuleConfig allows the traffic to a destination.
+type EgressRuleConfig struct {
+	CIDR     string `json:"CIDR" mapstructure:"CIDR" description:"Destination network"`
+	Protocol string `json:"Protocol" mapstructure:"Protocol" description:"tcp, udp or empty for any protocol"`
+	Ports    []int  `json:"Ports" mapstructure:"Ports" description:"Destination ports, empty for any port"`
+}
+
+// NewEgressPolicyConfigFromRequest returns the egress policy of the request, the mode defaults to allow-all.
+func NewEgressPolicyConfigFromRequest(egressPolicy *requests.EgressPolicyRequest) *EgressPolicyConfig {
+	if egressPolicy == nil {
+		return &EgressPolicyConfig{
+			Mode:  EgressPolicyAllowAll,
+			Rules: []*EgressRuleConfig{},
+		}
+	}
+	mode := strings.ToLower(egressPolicy.Mode)
+	if mode == "" {
+		mode = EgressPolicyAllowAll
+	}
+	rules := []*EgressRuleConfig{}
+	for _, rule := range egressPolicy.Allow {
+		rules = append(rules, &EgressRuleConfig{
+			CIDR:     rule.CIDR,
+			Protocol: strings.ToLower(rule.Protocol),
+			Ports:    rule.Ports,
+		})
+	}
+	return &EgressPolicyConfig{
+		Mode:  mode,
+		Rules: rules,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *EgressPolicyConfig) Validate() error {
+	switch c.Mode {
+	case EgressPolicyAllowAll, EgressPolicyDenyAll, EgressPolicyDNSOnly:
+		if len(c.Rules) > 0 {
+			return fmt.Errorf("egress policy rules are only allowed with the %s mode", EgressPolicyAllowlist)
+		}
+	case EgressPolicyAllowlist:
+		for i, rule := range c.Rules {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("egress policy rule %d: %s", i+1, err)
+			}
+		}
+	default:
+		return fmt.Errorf("egress policy mode must be one of %s, %s, %s or %s",
+			EgressPolicyAllowAll, EgressPolicyDenyAll, EgressPolicyDNSOnly, EgressPolicyAllowlist)
+	}
+	return nil
+}
+
+// Enforced returns true if the policy restricts the traffic.
+func (c *EgressPolicyConfig) Enforced() bool {
+	return c != nil && c.Mode != EgressPolicyAllowAll
+}
+
+// Validate validates the correctness of the configuration.
+func (c *EgressRuleConfig) Validate() error {
+	if _, _, err := net.ParseCIDR(c.CIDR); err != nil {
+		return fmt.Errorf("cidr is invalid: %s", err)
+	}
+	if c.Protocol != "" && c.Protocol != "tcp" && c.Protocol != "udp" {
+		return fmt.Errorf("protocol must be tcp, udp or empty")
+	}
+	if len(c.Ports) > 0 && c.Protocol == "" {
+		return fmt.Errorf("ports require the tcp or udp protocol")
+	}
+	if len(c.Ports) > EgressRuleMaxPorts {
+		return fmt.Errorf("a rule cannot have more than %d ports", EgressRuleMaxPorts)
+	}
+	for _, port := range c.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("ports must be between 1 and 65535")
+		}
+	}
+	return nil
+}
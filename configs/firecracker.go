@@ -10,10 +10,11 @@ import (
 	"strings"
 
 	"open-fire/pkg/strategy/arbitrary"
-	"open-fire/utils"
 
+	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/firecracker-microvm/firecracker-go-sdk"
 	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	"github.com/firecracker-microvm/firecracker-go-sdk/cni/vmconf"
 	"github.com/opentracing/opentracing-go/log"
 )
 
@@ -25,8 +26,7 @@ const (
 var (
 
 	// error parsing blockdevices
-	errInvalidDriveSpecificationNoSuffix = errors.New("invalid drive specification. Must have :rw or :ro suffix")
-	errInvalidDriveSpecificationNoPath   = errors.New("invalid drive specification. Must have path")
+	errInvalidDriveSpecificationNoPath = errors.New("invalid drive specification. Must have path")
 
 	// error parsing vsock
 	errUnableToParseVsockDevices = errors.New("unable to parse vsock devices")
@@ -71,10 +71,6 @@ func NewFcConfigProvider(jailingFcConfig *JailingFirecrackerConfig, machineConfi
 
 func (c *defaultFcConfigProvider) ToSDKConfig() (firecracker.Config, error) {
 
-	if c.machineConfig.Debug {
-		c.machineConfig.KernelArgs = c.machineConfig.KernelArgs + " console=ttyS0"
-	}
-
 	// console stick to terminal debug
 	// c.machineConfig.KernelArgs = "console=ttyS0 reboot=k panic=1 pci=off"
 
@@ -82,6 +78,11 @@ func (c *defaultFcConfigProvider) ToSDKConfig() (firecracker.Config, error) {
 	if err != nil {
 		return firecracker.Config{}, err
 	}
+	kernelArgs, err := c.machineConfig.BootArgs(c.getKernelArgsData(), c.getIPBootParam())
+	if err != nil {
+		return firecracker.Config{}, err
+	}
+
 	// BlockDevices
 	blockDevices, err := c.getBlockDevices()
 	if err != nil {
@@ -109,12 +110,14 @@ func (c *defaultFcConfigProvider) ToSDKConfig() (firecracker.Config, error) {
 		MetricsFifo:       c.machineConfig.FcMetricsFifo,
 		FifoLogWriter:     fifo,
 		KernelImagePath:   c.machineConfig.KernelPath,
-		KernelArgs:        c.machineConfig.KernelArgs,
+		InitrdPath:        c.machineConfig.InitrdPath,
+		KernelArgs:        kernelArgs,
 		NetNS:             c.jailingFcConfig.NetNS,
 		Drives:            blockDevices,
 		NetworkInterfaces: NICs,
 		VsockDevices:      vsocks,
-		MmdsVersion:       firecracker.MMDSv2,
+		MmdsVersion:       firecracker.MMDSVersion(c.machineConfig.FcMmdsVersion),
+		Seccomp:           c.machineConfig.SeccompConfig(),
 		MachineCfg: models.MachineConfiguration{
 			VcpuCount:   firecracker.Int64(c.machineConfig.CPU),
 			CPUTemplate: models.CPUTemplate(c.machineConfig.CPUTemplate),
@@ -139,7 +142,7 @@ func (c *defaultFcConfigProvider) ToSDKConfig() (firecracker.Config, error) {
 			Stdout:        os.Stdout,
 			Stderr:        os.Stderr,
 			Stdin:         os.Stdin,
-			CgroupVersion: "2",
+			CgroupVersion: c.machineConfig.CgroupVersion(),
 		},
 		VMID: c.jailingFcConfig.VMMID(),
 	}, nil
@@ -150,49 +153,72 @@ func (c *defaultFcConfigProvider) WithHandlersAdapter(input firecracker.Handlers
 	return c
 }
 
+// getNetwork converts the network interfaces, already added to their CNI networks, to static interfaces.
 func (c *defaultFcConfigProvider) getNetwork() ([]firecracker.NetworkInterface, error) {
 
-	if c.machineConfig.CNINetworkName == "" {
-		return nil, fmt.Errorf("cni network of machine cannot be empty")
+	if len(c.machineConfig.FcNetworkInterfaces) == 0 {
+		return nil, fmt.Errorf("machine must have at least one network interface")
 	}
 
 	var NICs []firecracker.NetworkInterface
 
-	nic := firecracker.NetworkInterface{
-		CNIConfiguration: &firecracker.CNIConfiguration{
-			NetworkName: c.machineConfig.CNINetworkName,
-			IfName:      DefaultVethIfaceName + utils.RandStringBytes(11),
-			Args: func() [][2]string {
-				return [][2]string{}
-			}(),
-		},
-		AllowMMDS: true,
-		InRateLimiter: &models.RateLimiter{
-			Bandwidth: &models.TokenBucket{
-				Size:       firecracker.Int64(0),
-				RefillTime: firecracker.Int64(0),
-			},
-			Ops: &models.TokenBucket{
-				Size:       firecracker.Int64(0),
-				RefillTime: firecracker.Int64(0),
-			},
-		},
-		OutRateLimiter: &models.RateLimiter{
-			Bandwidth: &models.TokenBucket{
-				Size:       firecracker.Int64(0),
-				RefillTime: firecracker.Int64(0),
-			},
-			Ops: &models.TokenBucket{
-				Size:       firecracker.Int64(0),
-				RefillTime: firecracker.Int64(0),
+	for _, iface := range c.machineConfig.FcNetworkInterfaces {
+		if iface.Result == nil {
+			return nil, fmt.Errorf("network interface %s was not set up on cni network %s", iface.IfName, iface.CNINetworkName)
+		}
+		nic := firecracker.NetworkInterface{
+			StaticConfiguration: &firecracker.StaticNetworkConfiguration{
+				HostDevName: iface.Result.TapName,
+				MacAddress:  iface.Result.MacAddress,
 			},
-		},
+			AllowMMDS:      iface.AllowMMDS,
+			InRateLimiter:  iface.InRateLimiter.ToModel(),
+			OutRateLimiter: iface.OutRateLimiter.ToModel(),
+		}
+		NICs = append(NICs, nic)
 	}
-	NICs = append(NICs, nic)
 
 	return NICs, nil
 }
 
+// getKernelArgsData returns the values of the templates of the kernel arguments, the addresses of the primary interface.
+func (c *defaultFcConfigProvider) getKernelArgsData() *KernelArgsData {
+	primary := c.machineConfig.FcNetworkInterfaces[0].Result
+
+	data := &KernelArgsData{
+		VMID:    c.jailingFcConfig.VMMID(),
+		Name:    c.machineConfig.Name,
+		IP:      primary.IPAddr.IP.String(),
+		Gateway: primary.Gateway.String(),
+	}
+	if primary.IPv6Addr != nil {
+		data.IPv6 = primary.IPv6Addr.IP.String()
+	}
+	return data
+}
+
+// getIPBootParam returns the ip= kernel argument configuring the primary interface in the guest.
+func (c *defaultFcConfigProvider) getIPBootParam() string {
+	primary := c.machineConfig.FcNetworkInterfaces[0].Result
+
+	vmConf := vmconf.StaticNetworkConf{
+		VMNameservers: primary.Nameservers,
+		VMIPConfig: &current.IPConfig{
+			Address: primary.IPAddr,
+			Gateway: primary.Gateway,
+		},
+	}
+	if len(vmConf.VMNameservers) > 2 {
+		vmConf.VMNameservers = vmConf.VMNameservers[:2]
+	}
+	// with a single interface the kernel picks the only device
+	if len(c.machineConfig.FcNetworkInterfaces) > 1 {
+		vmConf.VMIfName = "eth0"
+	}
+
+	return "ip=" + vmConf.IPBootParam()
+}
+
 // constructs a list of drives from the options config
 func (c *defaultFcConfigProvider) getBlockDevices() ([]models.Drive, error) {
 	blockDevices, err := parseBlockDevices(c.machineConfig.FcAdditionalDrives)
@@ -200,49 +226,59 @@ func (c *defaultFcConfigProvider) getBlockDevices() ([]models.Drive, error) {
 		return nil, err
 	}
 
-	rootDrivePath, readOnly := parseDevice(c.machineConfig.RootFSPath)
-	rootDrive := models.Drive{
-		DriveID:      firecracker.String("1"),
-		PathOnHost:   firecracker.String(rootDrivePath),
-		IsReadOnly:   firecracker.Bool(readOnly),
-		IsRootDevice: firecracker.Bool(true),
-		Partuuid:     c.machineConfig.FcRootPartUUID,
+	if c.machineConfig.HasRootDrive() {
+		rootDrive := models.Drive{
+			DriveID:      firecracker.String(RootDriveID),
+			PathOnHost:   firecracker.String(c.machineConfig.RootDrivePath()),
+			IsReadOnly:   firecracker.Bool(c.machineConfig.RootDriveReadOnly()),
+			IsRootDevice: firecracker.Bool(true),
+			Partuuid:     c.machineConfig.FcRootPartUUID,
+		}
+		blockDevices = append(blockDevices, rootDrive)
 	}
-	blockDevices = append(blockDevices, rootDrive)
+
+	for driveID, rateLimiter := range c.machineConfig.FcDriveRateLimiters {
+		found := false
+		for i := range blockDevices {
+			if firecracker.StringValue(blockDevices[i].DriveID) == driveID {
+				blockDevices[i].RateLimiter = rateLimiter.ToModel()
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("rate limiter given for unknown drive %s", driveID)
+		}
+	}
+
 	return blockDevices, nil
 }
 
-// given a []string in the form of path:suffix converts to []models.Drive
-func parseBlockDevices(entries []string) ([]models.Drive, error) {
+// converts the additional drives to []models.Drive
+func parseBlockDevices(entries []*DriveConfig) ([]models.Drive, error) {
 	devices := []models.Drive{}
 
-	for i, entry := range entries {
-		path := ""
-		readOnly := true
-
-		if strings.HasSuffix(entry, rwDeviceSuffix) {
-			readOnly = false
-			path = strings.TrimSuffix(entry, rwDeviceSuffix)
-		} else if strings.HasSuffix(entry, roDeviceSuffix) {
-			path = strings.TrimSuffix(entry, roDeviceSuffix)
-		} else {
-			return nil, errInvalidDriveSpecificationNoSuffix
-		}
-
-		if path == "" {
+	for _, entry := range entries {
+		if entry.Path == "" {
 			return nil, errInvalidDriveSpecificationNoPath
 		}
 
-		if _, err := os.Stat(path); err != nil {
+		if _, err := os.Stat(entry.Path); err != nil {
 			return nil, err
 		}
 
 		e := models.Drive{
-			// i + 2 represents the drive ID. We will reserve 1 for root.
-			DriveID:      firecracker.String(strconv.Itoa(i + 2)),
-			PathOnHost:   firecracker.String(path),
-			IsReadOnly:   firecracker.Bool(readOnly),
+			DriveID:      firecracker.String(entry.DriveID),
+			PathOnHost:   firecracker.String(entry.Path),
+			IsReadOnly:   firecracker.Bool(entry.ReadOnly),
 			IsRootDevice: firecracker.Bool(false),
+			Partuuid:     entry.Partuuid,
+			RateLimiter:  entry.RateLimiter.ToModel(),
+		}
+		if entry.CacheType != "" {
+			e.CacheType = firecracker.String(entry.CacheType)
+		}
+		if entry.IoEngine != "" {
+			e.IoEngine = firecracker.String(entry.IoEngine)
 		}
 		devices = append(devices, e)
 	}
@@ -0,0 +1,57 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"path/filepath"
+)
+
+// ImageBuildMaxSizeMib is the maximum size of a built root filesystem.
+const ImageBuildMaxSizeMib = 1 << 20
+
+// ImageBuildConfig provides the build of a root filesystem from a docker save archive.
+type ImageBuildConfig struct {
+	ArchivePath string `json:"ArchivePath" mapstructure:"ArchivePath" description:"Path of the docker save archive on the host"`
+	Tag         string `json:"Tag" mapstructure:"Tag" description:"Tag of the image in an archive holding several, the first image if empty"`
+	Name        string `json:"Name" mapstructure:"Name" description:"Name of the image in the catalog"`
+	Version     string `json:"Version" mapstructure:"Version" description:"Optional version of the image in the catalog"`
+	Description string `json:"Description" mapstructure:"Description" description:"Free form description"`
+	SizeMib     int    `json:"SizeMib" mapstructure:"SizeMib" description:"Size of the ext4 image, computed from the image contents if 0"`
+}
+
+// NewImageBuildConfigFromRequest returns the build of the request.
+func NewImageBuildConfigFromRequest(build *requests.BuildImageRequest) *ImageBuildConfig {
+	return &ImageBuildConfig{
+		ArchivePath: build.ArchivePath,
+		Tag:         build.Tag,
+		Name:        build.Name,
+		Version:     build.Version,
+		Description: build.Description,
+		SizeMib:     build.SizeMib,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *ImageBuildConfig) Validate() error {
+	if c.ArchivePath == "" || !filepath.IsAbs(c.ArchivePath) {
+		return fmt.Errorf("archivePath must be an absolute path")
+	}
+	if c.SizeMib < 0 || c.SizeMib > ImageBuildMaxSizeMib {
+		return fmt.Errorf("sizeMib must be between 0 and %d", ImageBuildMaxSizeMib)
+	}
+	return c.EntryConfig("").Validate()
+}
+
+// EntryConfig returns the catalog registration of the built image.
+func (c *ImageBuildConfig) EntryConfig(arch string) *CatalogEntryConfig {
+	if arch == "" {
+		arch = HostArch()
+	}
+	return &CatalogEntryConfig{
+		Kind:        CatalogKindImage,
+		Name:        c.Name,
+		Version:     c.Version,
+		Arch:        arch,
+		Description: c.Description,
+	}
+}
@@ -0,0 +1,337 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Cgroup versions of the host, given to the jailer with --cgroup-version.
+const (
+	CgroupV1 = "1"
+	CgroupV2 = "2"
+)
+
+const (
+	// ResourceLimitMinNoFile is the lowest no-file limit, Firecracker needs file descriptors for its devices and API.
+	ResourceLimitMinNoFile = 64
+	// ResourceLimitMaxNoFile is the highest no-file limit, the default fs.nr_open of the kernel.
+	ResourceLimitMaxNoFile = 1 << 20
+)
+
+var (
+	cpuMaxRegexp     = regexp.MustCompile(`^(max|[0-9]+)( [0-9]+)?$`)
+	cpusetCpusRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+	memoryMaxRegexp  = regexp.MustCompile(`^(max|[0-9]+[KMG]?)$`)
+	ioMaxRegexp      = regexp.MustCompile(`^[0-9]+:[0-9]+( (rbps|wbps|riops|wiops)=(max|[0-9]+))+$`)
+)
+
+// ioMaxV1Files maps the io.max keys of cgroup v2 to the blkio throttling files of cgroup v1.
+var ioMaxV1Files = map[string]string{
+	"rbps":  "blkio.throttle.read_bps_device",
+	"wbps":  "blkio.throttle.write_bps_device",
+	"riops": "blkio.throttle.read_iops_device",
+	"wiops": "blkio.throttle.write_iops_device",
+}
+
+var (
+	hostCgroupVersion     string
+	hostCgroupVersionOnce sync.Once
+)
+
+// HostCgroupVersion returns the cgroup version of the host: 2 when /sys/fs/cgroup is the unified hierarchy,
+// 1 for the legacy and hybrid hierarchies.
+func HostCgroupVersion() string {
+	hostCgroupVersionOnce.Do(func() {
+		hostCgroupVersion = CgroupV1
+		statfs := unix.Statfs_t{}
+		if err := unix.Statfs("/sys/fs/cgroup", &statfs); err == nil && statfs.Type == unix.CGROUP2_SUPER_MAGIC {
+			hostCgroupVersion = CgroupV2
+		}
+	})
+	return hostCgroupVersion
+}
+
+// JailerLimitsConfig provides the cgroup settings and the resource limits the jailer applies to a VM.
+// The cgroup settings use the cgroup v2 names, translated to the cgroup v1 files on v1 hosts.
+type JailerLimitsConfig struct {
+	CgroupVersion string   `json:"CgroupVersion" mapstructure:"CgroupVersion" description:"Cgroup version of the jailer, JAILER_CGROUP_VERSION, detected from the host if empty"`
+	CPUMax        string   `json:"CPUMax" mapstructure:"CPUMax" description:"cpu.max as '$MAX [$PERIOD]' in microseconds, JAILER_CPU_MAX"`
+	CpusetCpus    string   `json:"CpusetCpus" mapstructure:"CpusetCpus" description:"cpuset.cpus, the host CPUs of the VM as a list of CPUs and ranges, JAILER_CPUSET_CPUS"`
+	MemoryMax     string   `json:"MemoryMax" mapstructure:"MemoryMax" description:"memory.max in bytes with an optional K, M or G suffix, JAILER_MEMORY_MAX"`
+	IOMax         []string `json:"IOMax" mapstructure:"IOMax" description:"io.max lines as '$MAJ:$MIN rbps= wbps= riops= wiops=', JAILER_IO_MAX separated by ';'"`
+	Fsize         int64    `json:"Fsize" mapstructure:"Fsize" description:"fsize resource limit, the maximum size in bytes of the files created by Firecracker, JAILER_FSIZE"`
+	NoFile        int64    `json:"NoFile" mapstructure:"NoFile" description:"no-file resource limit, the maximum number of file descriptors of Firecracker, JAILER_NO_FILE"`
+}
+
+// NewJailerLimitsConfig returns the host defaults of the limits, read from the environment.
+func NewJailerLimitsConfig() *JailerLimitsConfig {
+	ioMax := []string{}
+	for _, line := range strings.Split(utils.GetenvOrDefault("JAILER_IO_MAX", ""), ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			ioMax = append(ioMax, line)
+		}
+	}
+	cgroupVersion := utils.GetenvOrDefault("JAILER_CGROUP_VERSION", "")
+	if cgroupVersion == "" {
+		cgroupVersion = HostCgroupVersion()
+	}
+	return &JailerLimitsConfig{
+		CgroupVersion: cgroupVersion,
+		CPUMax:        utils.GetenvOrDefault("JAILER_CPU_MAX", ""),
+		CpusetCpus:    utils.GetenvOrDefault("JAILER_CPUSET_CPUS", ""),
+		MemoryMax:     utils.GetenvOrDefault("JAILER_MEMORY_MAX", ""),
+		IOMax:         ioMax,
+		Fsize:         getenvInt64("JAILER_FSIZE"),
+		NoFile:        getenvInt64("JAILER_NO_FILE"),
+	}
+}
+
+// NewJailerLimitsConfigFromRequest returns the host defaults overridden by the settings given in the request.
+func NewJailerLimitsConfigFromRequest(cgroup *requests.CgroupRequest, resourceLimits *requests.ResourceLimitsRequest) *JailerLimitsConfig {
+	c := NewJailerLimitsConfig()
+	if cgroup != nil {
+		if cgroup.CPUMax != "" {
+			c.CPUMax = cgroup.CPUMax
+		}
+		if cgroup.CpusetCpus != "" {
+			c.CpusetCpus = cgroup.CpusetCpus
+		}
+		if cgroup.MemoryMax != "" {
+			c.MemoryMax = cgroup.MemoryMax
+		}
+		if len(cgroup.IOMax) > 0 {
+			c.IOMax = cgroup.IOMax
+		}
+	}
+	if resourceLimits != nil {
+		if resourceLimits.Fsize != 0 {
+			c.Fsize = resourceLimits.Fsize
+		}
+		if resourceLimits.NoFile != 0 {
+			c.NoFile = resourceLimits.NoFile
+		}
+	}
+	return c
+}
+
+// Validate validates the correctness of the configuration, the memory limit must leave room for the guest memory.
+func (c *JailerLimitsConfig) Validate(memSizeMib int64) error {
+	if c.CgroupVersion != CgroupV1 && c.CgroupVersion != CgroupV2 {
+		return fmt.Errorf("cgroup version must be %s or %s", CgroupV1, CgroupV2)
+	}
+
+	if c.CPUMax != "" {
+		if err := validateCPUMax(c.CPUMax); err != nil {
+			return err
+		}
+	}
+
+	if c.CpusetCpus != "" {
+		if err := validateCpusetCpus(c.CpusetCpus); err != nil {
+			return err
+		}
+	}
+
+	if c.MemoryMax != "" {
+		if !memoryMaxRegexp.MatchString(c.MemoryMax) {
+			return fmt.Errorf("cgroup memoryMax must be max or a number of bytes with an optional K, M or G suffix")
+		}
+		if c.MemoryMax != "max" && memoryBytes(c.MemoryMax) <= memSizeMib<<20 {
+			return fmt.Errorf("cgroup memoryMax must be greater than memSizeMib, the guest memory is charged to the VM cgroup")
+		}
+	}
+
+	// the jailer takes --cgroup as <file>=<value> with a single =, which the io.max lines of cgroup v2 cannot be
+	if len(c.IOMax) > 0 && c.CgroupVersion == CgroupV2 {
+		return fmt.Errorf("cgroup ioMax is only supported on cgroup v1 hosts, the jailer cannot set io.max on cgroup v2: remove it from the request or JAILER_IO_MAX")
+	}
+	for _, line := range c.IOMax {
+		if !ioMaxRegexp.MatchString(line) {
+			return fmt.Errorf("cgroup ioMax %q must be '$MAJ:$MIN' followed by rbps=, wbps=, riops= or wiops= limits", line)
+		}
+	}
+
+	if c.Fsize < 0 {
+		return fmt.Errorf("resource limit fsize cannot be negative")
+	}
+
+	if c.NoFile != 0 && (c.NoFile < ResourceLimitMinNoFile || c.NoFile > ResourceLimitMaxNoFile) {
+		return fmt.Errorf("resource limit noFile must be between %d and %d", ResourceLimitMinNoFile, ResourceLimitMaxNoFile)
+	}
+
+	return nil
+}
+
+// CgroupArgs returns the <file>=<value> settings given to the jailer with --cgroup, for the cgroup version.
+// The io.max lines are only translated on cgroup v1, Validate refuses them on cgroup v2.
+func (c *JailerLimitsConfig) CgroupArgs() []string {
+	args := []string{}
+
+	if c.CPUMax != "" {
+		quota, period, _ := strings.Cut(c.CPUMax, " ")
+		if c.CgroupVersion == CgroupV2 {
+			args = append(args, "cpu.max="+c.CPUMax)
+		} else {
+			if period != "" {
+				args = append(args, "cpu.cfs_period_us="+period)
+			}
+			if quota == "max" {
+				quota = "-1"
+			}
+			args = append(args, "cpu.cfs_quota_us="+quota)
+		}
+	}
+
+	if c.CpusetCpus != "" {
+		args = append(args, "cpuset.cpus="+c.CpusetCpus)
+	}
+
+	if c.MemoryMax != "" {
+		if c.CgroupVersion == CgroupV2 {
+			args = append(args, "memory.max="+c.MemoryMax)
+		} else if c.MemoryMax == "max" {
+			args = append(args, "memory.limit_in_bytes=-1")
+		} else {
+			args = append(args, "memory.limit_in_bytes="+c.MemoryMax)
+		}
+	}
+
+	for _, line := range c.IOMax {
+		if c.CgroupVersion == CgroupV2 {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, limit := range fields[1:] {
+			key, value, _ := strings.Cut(limit, "=")
+			// cgroup v1 has no limit by default, there is nothing to write for max
+			if value != "max" {
+				args = append(args, ioMaxV1Files[key]+"="+fields[0]+" "+value)
+			}
+		}
+	}
+
+	return args
+}
+
+// ResourceLimitArgs returns the <resource>=<value> limits given to the jailer with --resource-limit.
+func (c *JailerLimitsConfig) ResourceLimitArgs() []string {
+	args := []string{}
+	if c.Fsize > 0 {
+		args = append(args, "fsize="+strconv.FormatInt(c.Fsize, 10))
+	}
+	if c.NoFile > 0 {
+		args = append(args, "no-file="+strconv.FormatInt(c.NoFile, 10))
+	}
+	return args
+}
+
+// getenvInt64 returns the number of an environment variable, 0 if unset or empty and -1 if invalid, for validation to report it.
+func getenvInt64(key string) int64 {
+	value := strings.TrimSpace(utils.GetenvOrDefault(key, ""))
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return number
+}
+
+func validateCPUMax(cpuMax string) error {
+	if !cpuMaxRegexp.MatchString(cpuMax) {
+		return fmt.Errorf("cgroup cpuMax must be '$MAX [$PERIOD]', $MAX being max or a number of microseconds")
+	}
+	quota, period, hasPeriod := strings.Cut(cpuMax, " ")
+	if quota != "max" {
+		if value, _ := strconv.ParseInt(quota, 10, 64); value < 1000 {
+			return fmt.Errorf("cgroup cpuMax quota cannot be lower than 1000 microseconds")
+		}
+	}
+	if hasPeriod {
+		if value, _ := strconv.ParseInt(period, 10, 64); value < 1000 || value > 1000000 {
+			return fmt.Errorf("cgroup cpuMax period must be between 1000 and 1000000 microseconds")
+		}
+	}
+	return nil
+}
+
+// validateCpusetCpus checks the CPU list is well formed and only has online host CPUs.
+func validateCpusetCpus(cpusetCpus string) error {
+	if !cpusetCpusRegexp.MatchString(cpusetCpus) {
+		return fmt.Errorf("cgroup cpusetCpus must be a list of CPUs and CPU ranges, as 0-3,6")
+	}
+	cpus, err := parseCPUList(cpusetCpus)
+	if err != nil {
+		return fmt.Errorf("cgroup cpusetCpus %s", err)
+	}
+	online, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return nil
+	}
+	onlineCPUs, err := parseCPUList(strings.TrimSpace(string(online)))
+	if err != nil {
+		return nil
+	}
+	for cpu := range cpus {
+		if !onlineCPUs[cpu] {
+			return fmt.Errorf("cgroup cpusetCpus has CPU %d, the online host CPUs are %s", cpu, strings.TrimSpace(string(online)))
+		}
+	}
+	return nil
+}
+
+// parseCPUList returns the CPUs of a list as 0-3,6.
+func parseCPUList(list string) (map[int]bool, error) {
+	cpus := map[int]bool{}
+	for _, cpuRange := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(cpuRange, "-")
+		if !isRange {
+			last = first
+		}
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, err
+		}
+		to, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, err
+		}
+		if from > to {
+			return nil, fmt.Errorf("range %s is reversed", cpuRange)
+		}
+		for cpu := from; cpu <= to && cpu < 1<<16; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// memoryBytes returns the bytes of a memory limit with an optional K, M or G suffix.
+func memoryBytes(memoryMax string) int64 {
+	shift := 0
+	switch memoryMax[len(memoryMax)-1] {
+	case 'K':
+		shift = 10
+	case 'M':
+		shift = 20
+	case 'G':
+		shift = 30
+	}
+	if shift > 0 {
+		memoryMax = memoryMax[:len(memoryMax)-1]
+	}
+	value, err := strconv.ParseInt(memoryMax, 10, 64)
+	if err != nil || value > (1<<62)>>shift {
+		return 1 << 62
+	}
+	return value << shift
+}
@@ -0,0 +1,139 @@
+package configs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCgroupArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits JailerLimitsConfig
+		want   []string
+	}{
+		{
+			name:   "no settings",
+			limits: JailerLimitsConfig{CgroupVersion: CgroupV2},
+			want:   []string{},
+		},
+		{
+			name: "cgroup v2",
+			limits: JailerLimitsConfig{
+				CgroupVersion: CgroupV2,
+				CPUMax:        "50000 100000",
+				CpusetCpus:    "2-3",
+				MemoryMax:     "1200M",
+			},
+			want: []string{"cpu.max=50000 100000", "cpuset.cpus=2-3", "memory.max=1200M"},
+		},
+		{
+			name: "cgroup v2 ioMax is never passed",
+			limits: JailerLimitsConfig{
+				CgroupVersion: CgroupV2,
+				IOMax:         []string{"8:0 rbps=1048576"},
+			},
+			want: []string{},
+		},
+		{
+			name: "cgroup v1",
+			limits: JailerLimitsConfig{
+				CgroupVersion: CgroupV1,
+				CPUMax:        "50000 100000",
+				CpusetCpus:    "2-3",
+				MemoryMax:     "1200M",
+			},
+			want: []string{"cpu.cfs_period_us=100000", "cpu.cfs_quota_us=50000", "cpuset.cpus=2-3", "memory.limit_in_bytes=1200M"},
+		},
+		{
+			name: "cgroup v1 max",
+			limits: JailerLimitsConfig{
+				CgroupVersion: CgroupV1,
+				CPUMax:        "max",
+				MemoryMax:     "max",
+			},
+			want: []string{"cpu.cfs_quota_us=-1", "memory.limit_in_bytes=-1"},
+		},
+		{
+			name: "cgroup v1 ioMax",
+			limits: JailerLimitsConfig{
+				CgroupVersion: CgroupV1,
+				IOMax:         []string{"8:0 rbps=1048576 wbps=max riops=100", "259:0 wiops=200"},
+			},
+			want: []string{
+				"blkio.throttle.read_bps_device=8:0 1048576",
+				"blkio.throttle.read_iops_device=8:0 100",
+				"blkio.throttle.write_iops_device=259:0 200",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.limits.CgroupArgs()
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("CgroupArgs() = %q, want %q", got, test.want)
+			}
+			// the jailer refuses the settings with more than one =
+			for _, arg := range got {
+				if strings.Count(arg, "=") != 1 {
+					t.Errorf("%q is not <file>=<value>", arg)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceLimitArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits JailerLimitsConfig
+		want   []string
+	}{
+		{name: "no limits", limits: JailerLimitsConfig{}, want: []string{}},
+		{name: "fsize", limits: JailerLimitsConfig{Fsize: 1073741824}, want: []string{"fsize=1073741824"}},
+		{name: "both", limits: JailerLimitsConfig{Fsize: 1024, NoFile: 4096}, want: []string{"fsize=1024", "no-file=4096"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.limits.ResourceLimitArgs(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("ResourceLimitArgs() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJailerLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  JailerLimitsConfig
+		wantErr string
+	}{
+		{name: "no settings", limits: JailerLimitsConfig{CgroupVersion: CgroupV2}},
+		{name: "unknown cgroup version", limits: JailerLimitsConfig{CgroupVersion: "3"}, wantErr: "cgroup version"},
+		{name: "cgroup v1 ioMax", limits: JailerLimitsConfig{CgroupVersion: CgroupV1, IOMax: []string{"8:0 rbps=1048576"}}},
+		{name: "cgroup v2 ioMax", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, IOMax: []string{"8:0 rbps=1048576"}}, wantErr: "cgroup v1"},
+		{name: "invalid ioMax", limits: JailerLimitsConfig{CgroupVersion: CgroupV1, IOMax: []string{"8:0 rbps"}}, wantErr: "ioMax"},
+		{name: "cpuMax quota", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, CPUMax: "999"}, wantErr: "quota"},
+		{name: "memoryMax below the guest memory", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, MemoryMax: "512M"}, wantErr: "memSizeMib"},
+		{name: "memoryMax", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, MemoryMax: "1G"}},
+		{name: "noFile too low", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, NoFile: 10}, wantErr: "noFile"},
+		{name: "negative fsize", limits: JailerLimitsConfig{CgroupVersion: CgroupV2, Fsize: -1}, wantErr: "fsize"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.limits.Validate(512)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() failed: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package configs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// kernelArgsMaxLength is the maximum length of the kernel command line of the x86_64 and aarch64 guests.
+const kernelArgsMaxLength = 2048
+
+// kernelArgsDenylist holds the keys of the kernel arguments the requests cannot set: the ones managed by the server,
+// the ones Firecracker guests need to boot and stop, and the ones weakening the guest mitigations.
+var kernelArgsDenylist = map[string]string{
+	"ip":            "the guest network is configured by the server",
+	"console":       "the serial console is enabled by debug",
+	"8250.nr_uarts": "the serial console is enabled by debug",
+	"pci":           "Firecracker guests have no PCI bus",
+	"reboot":        "the VM stops on guest reboot",
+	"panic":         "the VM stops on guest panic",
+	"mem":           "the memory is set by memSizeMib",
+	"memmap":        "the memory is set by memSizeMib",
+	"nosmt":         "SMT is set by enableSmt",
+	"l1tf":          "the guest mitigations cannot be changed",
+	"mitigations":   "the guest mitigations cannot be changed",
+	"nospectre_v1":  "the guest mitigations cannot be changed",
+	"nospectre_v2":  "the guest mitigations cannot be changed",
+	"spectre_v2":    "the guest mitigations cannot be changed",
+	"nopti":         "the guest mitigations cannot be changed",
+	"pti":           "the guest mitigations cannot be changed",
+	"mds":           "the guest mitigations cannot be changed",
+}
+
+// KernelArgsData holds the values available to the templates of the kernel arguments, as {{.VMID}} or {{.IP}}.
+type KernelArgsData struct {
+	VMID    string
+	Name    string
+	IP      string
+	IPv6    string
+	Gateway string
+}
+
+// validateKernelArgs validates the kernel arguments of a request, rendered with placeholder values.
+func validateKernelArgs(kernelArgs string) error {
+	if len(kernelArgs) > kernelArgsMaxLength/2 {
+		return fmt.Errorf("kernelArgs cannot be longer than %d characters", kernelArgsMaxLength/2)
+	}
+	// the keys are checked against the denylist once rendered, they must not depend on the VM
+	args, _ := splitKernelArgs(kernelArgs)
+	for _, arg := range args {
+		if key, _, _ := strings.Cut(arg, "="); strings.Contains(key, "{{") {
+			return fmt.Errorf("kernel argument %s cannot be set: the keys cannot be templates", key)
+		}
+	}
+	rendered, err := renderKernelArgs(kernelArgs, &KernelArgsData{
+		VMID:    "vmid",
+		Name:    "name",
+		IP:      "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		Gateway: "192.0.2.254",
+	})
+	if err != nil {
+		return err
+	}
+	renderedArgs, _ := splitKernelArgs(rendered)
+	return checkKernelArgsDenylist(renderedArgs)
+}
+
+// checkKernelArgsDenylist returns an error for the first argument with a key of the denylist.
+func checkKernelArgsDenylist(args []string) error {
+	for _, arg := range args {
+		if reason, denied := kernelArgsDenylist[kernelArgKey(arg)]; denied {
+			return fmt.Errorf("kernel argument %s cannot be set: %s", kernelArgKey(arg), reason)
+		}
+	}
+	return nil
+}
+
+// BootArgs returns the kernel command line of the machine: the default arguments, the serial console in debug mode
+// and the managed arguments, overridden by the rendered kernel arguments of the request with the same keys.
+func (c *MachineConfig) BootArgs(data *KernelArgsData, managedArgs ...string) (string, error) {
+	args, _ := splitKernelArgs(c.KernelArgs)
+	if c.Debug {
+		args = append(args, "console=ttyS0")
+	}
+	args = mergeKernelArgs(args, managedArgs)
+
+	rendered, err := renderKernelArgs(c.FcKernelArgs, data)
+	if err != nil {
+		return "", err
+	}
+	overrides, initArgs := splitKernelArgs(rendered)
+	// the values of the VM may render differently from the placeholders the request was validated with
+	if err := checkKernelArgsDenylist(overrides); err != nil {
+		return "", err
+	}
+	args = mergeKernelArgs(args, overrides)
+
+	bootArgs := strings.Join(args, " ")
+	if len(initArgs) > 0 {
+		bootArgs += " -- " + strings.Join(initArgs, " ")
+	}
+	if len(bootArgs) > kernelArgsMaxLength {
+		return "", fmt.Errorf("kernel command line is longer than %d characters", kernelArgsMaxLength)
+	}
+	return bootArgs, nil
+}
+
+func renderKernelArgs(kernelArgs string, data *KernelArgsData) (string, error) {
+	if strings.Contains(kernelArgs, `"`) {
+		return "", fmt.Errorf("kernelArgs cannot contain quotes")
+	}
+	tmpl, err := template.New("kernelArgs").Option("missingkey=error").Parse(kernelArgs)
+	if err != nil {
+		return "", fmt.Errorf("invalid kernelArgs template: %s", err)
+	}
+	rendered := &bytes.Buffer{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return "", fmt.Errorf("invalid kernelArgs template: %s", err)
+	}
+	return rendered.String(), nil
+}
+
+// splitKernelArgs splits a command line into the kernel arguments and the init arguments, following --.
+func splitKernelArgs(kernelArgs string) ([]string, []string) {
+	args := strings.Fields(kernelArgs)
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+// mergeKernelArgs replaces the arguments with the key of an override, in place, and appends the other overrides.
+// The last override of a key wins.
+func mergeKernelArgs(args, overrides []string) []string {
+	result := append([]string{}, args...)
+	for _, override := range overrides {
+		key := kernelArgKey(override)
+		merged := []string{}
+		replaced := false
+		for _, arg := range result {
+			if kernelArgKey(arg) != key {
+				merged = append(merged, arg)
+			} else if !replaced {
+				merged = append(merged, override)
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+		result = merged
+	}
+	return result
+}
+
+func kernelArgKey(arg string) string {
+	key, _, _ := strings.Cut(arg, "=")
+	// the kernel treats - and _ alike in the parameter names
+	return strings.ReplaceAll(key, "-", "_")
+}
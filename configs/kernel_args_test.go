@@ -0,0 +1,148 @@
+package configs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeKernelArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		overrides []string
+		want      []string
+	}{
+		{name: "no overrides", args: []string{"quiet", "rw"}, want: []string{"quiet", "rw"}},
+		{name: "new keys are appended", args: []string{"quiet"}, overrides: []string{"loglevel=7"}, want: []string{"quiet", "loglevel=7"}},
+		{name: "replaced in place", args: []string{"quiet", "loglevel=1", "rw"}, overrides: []string{"loglevel=7"}, want: []string{"quiet", "loglevel=7", "rw"}},
+		{name: "flag replaced by a value", args: []string{"quiet", "rw"}, overrides: []string{"quiet=1"}, want: []string{"quiet=1", "rw"}},
+		{name: "last override wins", args: []string{"loglevel=1"}, overrides: []string{"loglevel=4", "loglevel=7"}, want: []string{"loglevel=7"}},
+		{name: "last appended override wins", overrides: []string{"foo=1", "bar", "foo=2"}, want: []string{"foo=2", "bar"}},
+		{name: "duplicate keys are deduplicated", args: []string{"foo=1", "quiet", "foo=2"}, overrides: []string{"foo=3"}, want: []string{"foo=3", "quiet"}},
+		{name: "- and _ are the same key", args: []string{"init_on_free=0"}, overrides: []string{"init-on-free=1"}, want: []string{"init-on-free=1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mergeKernelArgs(test.args, test.overrides); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("mergeKernelArgs(%q, %q) = %q, want %q", test.args, test.overrides, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckKernelArgsDenylist(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "allowed", args: []string{"quiet", "loglevel=7", "init_on_free=1"}},
+		{name: "denied key", args: []string{"quiet", "mitigations=off"}, wantErr: "mitigations"},
+		{name: "denied flag", args: []string{"nopti"}, wantErr: "nopti"},
+		{name: "- normalized to _", args: []string{"nospectre-v2"}, wantErr: "nospectre_v2"},
+		{name: "dotted key", args: []string{"8250.nr-uarts=1"}, wantErr: "8250.nr_uarts"},
+		{name: "value is not a key", args: []string{"foo=mitigations"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkKernelArgsDenylist(test.args)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkKernelArgsDenylist(%q) failed: %s", test.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("checkKernelArgsDenylist(%q) = %v, want an error about %s", test.args, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateKernelArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		kernelArgs string
+		wantErr    string
+	}{
+		{name: "empty", kernelArgs: ""},
+		{name: "templated values", kernelArgs: "hostname={{.Name}} id={{.VMID}} gw={{.Gateway}}"},
+		{name: "denied key", kernelArgs: "quiet mitigations=off", wantErr: "mitigations"},
+		{name: "- normalized to _", kernelArgs: "nospectre-v2", wantErr: "nospectre_v2"},
+		{name: "templated key", kernelArgs: "{{.Name}}=1", wantErr: "templates"},
+		{name: "denylist applied after rendering", kernelArgs: "x={{printf `a mitigations=off`}}", wantErr: "mitigations"},
+		{name: "init args are not checked", kernelArgs: "quiet -- mitigations=off"},
+		{name: "quotes", kernelArgs: `foo="a b"`, wantErr: "quotes"},
+		{name: "unknown field", kernelArgs: "foo={{.Missing}}", wantErr: "template"},
+		{name: "too long", kernelArgs: "foo=" + strings.Repeat("a", kernelArgsMaxLength/2), wantErr: "longer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateKernelArgs(test.kernelArgs)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateKernelArgs(%q) failed: %s", test.kernelArgs, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("validateKernelArgs(%q) = %v, want an error about %s", test.kernelArgs, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestBootArgs(t *testing.T) {
+	data := &KernelArgsData{VMID: "vmid", Name: "vm", IP: "192.0.2.1", Gateway: "192.0.2.254"}
+	tests := []struct {
+		name         string
+		kernelArgs   string
+		fcKernelArgs string
+		debug        bool
+		data         *KernelArgsData
+		managedArgs  []string
+		want         string
+		wantErr      string
+	}{
+		{name: "defaults", kernelArgs: "quiet loglevel=1 rw", want: "quiet loglevel=1 rw"},
+		{name: "debug console", kernelArgs: "quiet", debug: true, want: "quiet console=ttyS0"},
+		{name: "managed args", kernelArgs: "quiet", managedArgs: []string{"ip=192.0.2.1::192.0.2.254"}, want: "quiet ip=192.0.2.1::192.0.2.254"},
+		{name: "overridden in place", kernelArgs: "quiet loglevel=1 rw", fcKernelArgs: "loglevel=7 hostname={{.Name}}", want: "quiet loglevel=7 rw hostname=vm"},
+		{name: "init args split", kernelArgs: "quiet -- ignored", fcKernelArgs: "loglevel=7 -- --verbose {{.VMID}}", want: "quiet loglevel=7 -- --verbose vmid"},
+		{
+			name:         "denylist applied to the rendered values of the vm",
+			kernelArgs:   "quiet",
+			fcKernelArgs: "hostname={{.Name}}",
+			data:         &KernelArgsData{Name: "a mitigations=off"},
+			wantErr:      "mitigations",
+		},
+		{name: "too long", kernelArgs: strings.Repeat("a", kernelArgsMaxLength+1), wantErr: "longer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machineConfig := &MachineConfig{KernelArgs: test.kernelArgs, FcKernelArgs: test.fcKernelArgs, Debug: test.debug}
+			testData := data
+			if test.data != nil {
+				testData = test.data
+			}
+			got, err := machineConfig.BootArgs(testData, test.managedArgs...)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("BootArgs() = %q, %v, want an error about %s", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BootArgs() failed: %s", err)
+			}
+			if got != test.want {
+				t.Fatalf("BootArgs() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"net"
 	"open-fire/dtos/requests"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
+// vmNameRegexp matches a DNS label, the name is resolved as <name>.<network>.internal.
+var vmNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
 // MachineConfig provides machine configuration options.
 type MachineConfig struct {
+	Name              string `json:"Name" mapstructure:"Name" description:"Optional VM name, unique among the running VMs and resolved by the embedded DNS"`
 	CNINetworkName    string `json:"CniNetworkName" mapstructure:"CniNetworkName" description:"CNI network within which the build should run; it's recommended to use a dedicated network for build process"`
 	CPU               int64  `json:"CPU" mapstructure:"CPU" description:"Number of CPUs for the build VMM"`
-	CPUTemplate       string `json:"CPUTemplate" mapstructure:"CPUTemplate" description:"CPU template (empty, C2 or T3)"`
+	CPUTemplate       string `json:"CPUTemplate" mapstructure:"CPUTemplate" description:"Static CPU template of the host architecture, none if empty"`
 	Smt               bool   `json:"Smt" mapstructure:"Smt" description:"Flag for enabling/disabling simultaneous multithreading. Can be enabled only on x86."`
 	IPAddress         string `json:"IPAddress" mapstructure:"IPAddress" description:"IP address to try to allocate to the VM; if not given, a new IP will be allocated"`
 	KernelArgs        string `json:"KernelArgs" mapstructure:"KernelArgs" description:"Kernel arguments"`
@@ -19,19 +26,38 @@ type MachineConfig struct {
 	RootDrivePartUUID string `json:"RootDrivePartuuid" mapstructure:"RootDrivePartuuid" description:"Root drive part UUID"`
 	SSHUser           string `json:"SSHUser" mapstructure:"SSHUser" description:"SSH user"`
 
-	LogFcHTTPCalls                 bool            `json:"LogFirecrackerHTTPCalls" mapstructure:"LogFirecrackerHTTPCalls" description:"If set, logs Firecracker HTTP client calls in debug mode"`
-	ShutdownGracefulTimeoutSeconds int             `json:"ShutdownGracefulTimeoutSeconds" mapstructure:"ShutdownGracefulTimeoutSeconds" description:"Graceful shutdown timeout before vmm is stopped forcefully"`
-	KernelPath                     string          `json:"KernelPath" mapstructure:"KernelPath" description:"The path of the Kernel in the Host Machine"`
-	RootFSPath                     string          `json:"RootFSPath" mapstructure:"RootFSPath" description:"The path of the Root File System in the Host Machine"`
-	FcAdditionalDrives             []string        `long:"add-drive" description:"Path to additional drive, suffixed with :ro or :rw, can be specified multiple times"`
-	FcRootPartUUID                 string          `long:"root-partition" description:"Root partition UUID"`
-	FcVsockDevices                 []string        `long:"vsock-device" description:"Vsock interface, specified as PATH:CID. Multiple OK"`
-	FcLogFifo                      string          `long:"vmm-log-fifo" description:"FIFO for firecracker logs"`
-	FcFifoLogFile                  string          `long:"firecracker-log" short:"l" description:"pipes the fifo contents to the specified file"`
-	FcMetricsFifo                  string          `long:"metrics-fifo" description:"FIFO for firecracker metrics"`
-	FcMetadata                     *MetadataConfig `json:"Metadata" description:"Metadata validated to be used in the call of SetMetadata in FC"`
-	Debug                          bool            `json:"Debug" mapstructure:"Debug" description:"If debug should be enabled"`
-	LogLevel                       string          `json:"LogLevel" mapstructure:"LogLevel" description:"LogLevel defines the verbosity of Firecracker logging.  Valid values are Error, Warning, Info (default), and Debug, and are case-sensitive."`
+	LogFcHTTPCalls                 bool                          `json:"LogFirecrackerHTTPCalls" mapstructure:"LogFirecrackerHTTPCalls" description:"If set, logs Firecracker HTTP client calls in debug mode"`
+	ShutdownGracefulTimeoutSeconds int                           `json:"ShutdownGracefulTimeoutSeconds" mapstructure:"ShutdownGracefulTimeoutSeconds" description:"Graceful shutdown timeout before vmm is stopped forcefully"`
+	KernelPath                     string                        `json:"KernelPath" mapstructure:"KernelPath" description:"The path of the Kernel in the Host Machine"`
+	RootFSPath                     string                        `json:"RootFSPath" mapstructure:"RootFSPath" description:"The path of the Root File System in the Host Machine"`
+	InitrdPath                     string                        `json:"InitrdPath" mapstructure:"InitrdPath" description:"The path of the initrd in the Host Machine, the only root of the guest if RootFSPath is empty"`
+	Kernel                         string                        `json:"Kernel" mapstructure:"Kernel" description:"Catalog ID of the kernel, empty when given by path"`
+	FcKernelArgs                   string                        `json:"RequestKernelArgs" description:"Kernel arguments of the request, added to KernelArgs or overriding them by key, templated with KernelArgsData"`
+	Image                          string                        `json:"Image" mapstructure:"Image" description:"Catalog ID of the root filesystem, empty when given by path"`
+	Initrd                         string                        `json:"Initrd" mapstructure:"Initrd" description:"Catalog ID of the initrd, empty when given by path"`
+	FcAdditionalDrives             []*DriveConfig                `json:"AdditionalDrives" description:"Additional drives, attached after the root drive"`
+	FcRootFSClone                  string                        `json:"RootFSClone" description:"Writable copy-on-write clone of the root filesystem the VM boots from, empty for a read-only root drive"`
+	FcPersistRootfs                bool                          `json:"PersistRootfs" description:"If the root filesystem clone is kept in the disks directory when the VM stops"`
+	FcRootfsSizeMib                int                           `json:"RootfsSizeMib" description:"Size the root filesystem clone is grown to before boot, the size of the image if 0"`
+	FcCustomCPUTemplate            string                        `json:"CustomCPUTemplate" description:"Name of the custom CPU template, applied with PUT /cpu-config, none if empty"`
+	FcCPUConfigPath                string                        `json:"CPUConfigPath" description:"Path of the custom CPU template file, resolved at start"`
+	FcSeccompFilter                string                        `json:"SeccompFilter" description:"Seccomp filter of Firecracker: default for the built-in filters, none to disable seccomp or a custom filter name"`
+	FcSeccompFilterPath            string                        `json:"SeccompFilterPath" description:"Path of the compiled custom seccomp filter, resolved at start"`
+	FcRootPartUUID                 string                        `long:"root-partition" description:"Root partition UUID"`
+	FcVsockDevices                 []string                      `long:"vsock-device" description:"Vsock interface, specified as PATH:CID. Multiple OK"`
+	FcLogFifo                      string                        `long:"vmm-log-fifo" description:"FIFO for firecracker logs"`
+	FcFifoLogFile                  string                        `long:"firecracker-log" short:"l" description:"pipes the fifo contents to the specified file"`
+	FcMetricsFifo                  string                        `long:"metrics-fifo" description:"FIFO for firecracker metrics"`
+	FcMmdsVersion                  string                        `json:"MmdsVersion" description:"MMDS version, V2 (default) requires a session token, V1 also serves IMDSv1 clients"`
+	FcCloudInit                    *CloudInitConfig              `json:"CloudInit" description:"cloud-init user data and SSH keys"`
+	FcMetadata                     *MetadataConfig               `json:"Metadata" description:"Metadata validated to be used in the call of SetMetadata in FC"`
+	FcBalloon                      *BalloonConfig                `json:"Balloon" description:"Memory balloon device, nil if the VM has no balloon"`
+	FcNetworkInterfaces            []*NetworkInterfaceConfig     `json:"NetworkInterfaces" description:"Network interfaces of the VM, the first one is the primary interface"`
+	FcDriveRateLimiters            map[string]*RateLimiterConfig `json:"DriveRateLimiters" description:"Rate limiters by drive ID, the root drive has the ID 1"`
+	FcEgressPolicy                 *EgressPolicyConfig           `json:"EgressPolicy" description:"Egress network policy of the VM"`
+	FcJailerLimits                 *JailerLimitsConfig           `json:"JailerLimits" description:"Cgroup settings and resource limits applied by the jailer"`
+	Debug                          bool                          `json:"Debug" mapstructure:"Debug" description:"If debug should be enabled"`
+	LogLevel                       string                        `json:"LogLevel" mapstructure:"LogLevel" description:"LogLevel defines the verbosity of Firecracker logging.  Valid values are Error, Warning, Info (default), and Debug, and are case-sensitive."`
 
 	closers []func() error
 
@@ -57,6 +83,7 @@ func NewMachineConfig() *MachineConfig {
 		Debug:                          false,
 		LogLevel:                       "Info",
 		FcMetadata:                     NewMetadataConfig(),
+		FcMmdsVersion:                  "V2",
 	}
 }
 
@@ -71,8 +98,20 @@ func (c *MachineConfig) WithDaemonize(input bool) *MachineConfig {
 	return c
 }
 
+// CgroupVersion returns the cgroup version the jailer uses for the machine.
+func (c *MachineConfig) CgroupVersion() string {
+	if c.FcJailerLimits == nil {
+		return HostCgroupVersion()
+	}
+	return c.FcJailerLimits.CgroupVersion
+}
+
 // Validate validates the correctness of the configuration.
 func (c *MachineConfig) Validate() error {
+	if c.Name != "" && !vmNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("name must be a DNS label: up to 63 lowercase letters, digits or '-', not starting or ending with '-'")
+	}
+
 	if c.IPAddress != "" {
 		if parsedIP := net.ParseIP(c.IPAddress); parsedIP == nil {
 			return fmt.Errorf("value of --ip-address is not an IP address")
@@ -83,8 +122,43 @@ func (c *MachineConfig) Validate() error {
 		return fmt.Errorf("kernel path cannot be empty")
 	}
 
-	if c.RootFSPath == "" {
-		return fmt.Errorf("rootfs path cannot be empty")
+	if c.RootFSPath == "" && c.InitrdPath == "" {
+		return fmt.Errorf("rootfs path cannot be empty without an initrd")
+	}
+
+	// the kernel and the initrd are hard linked in the jail under their file name
+	if c.InitrdPath != "" && filepath.Base(c.InitrdPath) == filepath.Base(c.KernelPath) {
+		return fmt.Errorf("the kernel and the initrd must have different file names")
+	}
+
+	if c.FcRootfsSizeMib != 0 {
+		if c.FcRootfsSizeMib < 0 || c.FcRootfsSizeMib > RootfsMaxSizeMib {
+			return fmt.Errorf("rootfsSizeMib must be between 0 and %d", RootfsMaxSizeMib)
+		}
+		if !c.HasRootDrive() || c.RootDriveReadOnly() {
+			return fmt.Errorf("rootfsSizeMib needs a writable root drive")
+		}
+	}
+
+	if c.CPUTemplate != "" {
+		if err := validateStaticCPUTemplate(c.CPUTemplate); err != nil {
+			return err
+		}
+	}
+	if c.FcCustomCPUTemplate != "" {
+		if err := ValidateCustomCPUTemplateName(c.FcCustomCPUTemplate); err != nil {
+			return err
+		}
+	}
+
+	if IsCustomSeccompFilter(c.FcSeccompFilter) {
+		if err := ValidateCustomSeccompFilterName(c.FcSeccompFilter); err != nil {
+			return err
+		}
+	}
+
+	if err := validateKernelArgs(c.FcKernelArgs); err != nil {
+		return err
 	}
 
 	logLevel := []string{"Error", "Warning", "Info", "Debug"}
@@ -101,6 +175,53 @@ func (c *MachineConfig) Validate() error {
 		return fmt.Errorf("number of MemSizeMib cannot be lower than 128")
 	}
 
+	if c.FcMmdsVersion != "V1" && c.FcMmdsVersion != "V2" {
+		return fmt.Errorf("mmdsVersion must be V1 or V2")
+	}
+
+	if c.FcCloudInit != nil {
+		if err := c.FcCloudInit.Validate(); err != nil {
+			return err
+		}
+		for _, drive := range c.FcAdditionalDrives {
+			if c.FcCloudInit.OnSeedDrive() && drive.DriveID == SeedDriveID {
+				return fmt.Errorf("drive ID %s is reserved for the cloud-init seed drive", SeedDriveID)
+			}
+		}
+	}
+
+	if c.FcBalloon != nil {
+		if err := c.FcBalloon.Validate(c.Mem); err != nil {
+			return err
+		}
+	}
+
+	if err := validateNetworkInterfaces(c.FcNetworkInterfaces); err != nil {
+		return err
+	}
+
+	if err := validateDrives(c.FcAdditionalDrives, c.FcDriveRateLimiters, c.jailFiles()); err != nil {
+		return err
+	}
+
+	for driveID, rateLimiter := range c.FcDriveRateLimiters {
+		if err := rateLimiter.Validate(); err != nil {
+			return fmt.Errorf("invalid rate limiter for drive %s: %s", driveID, err)
+		}
+	}
+
+	if c.FcEgressPolicy != nil {
+		if err := c.FcEgressPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.FcJailerLimits != nil {
+		if err := c.FcJailerLimits.Validate(c.Mem); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -119,22 +240,57 @@ func (c *MachineConfig) WithCreateVMRequest(createVM *requests.CreateVMRequest)
 		c.LogLevel = "Error"
 	}
 
+	c.Name = createVM.Name
 	c.KernelPath = createVM.KernelPath
 	c.RootFSPath = createVM.RootDrivePath
+	c.InitrdPath = createVM.InitrdPath
+	c.Initrd = createVM.Initrd
+	c.FcPersistRootfs = createVM.PersistRootfs
+	c.FcRootfsSizeMib = createVM.RootfsSizeMib
+	c.Kernel = createVM.Kernel
+	c.FcKernelArgs = createVM.KernelArgs
+	if template := staticCPUTemplate(createVM.CPUTemplate); template != "" {
+		c.CPUTemplate = template
+	} else {
+		c.FcCustomCPUTemplate = createVM.CPUTemplate
+	}
+	c.FcSeccompFilter = NewSeccompFiltersConfig().Default
+	if createVM.SeccompFilter != "" {
+		c.FcSeccompFilter = normalizeSeccompFilter(createVM.SeccompFilter)
+	}
+	c.Image = createVM.Image
 	c.CNINetworkName = createVM.CniNetworkName
-
-	if createVM.AdditionalDrives != "" {
-		c.FcAdditionalDrives = append(c.FcAdditionalDrives, createVM.AdditionalDrives)
+	if len(createVM.NetworkInterfaces) > 0 {
+		c.CNINetworkName = createVM.NetworkInterfaces[0].CniNetworkName
 	}
 
-	if createVM.Metadata.Data != "" {
-		c.FcMetadata = (*MetadataConfig)(&createVM.Metadata)
+	c.FcAdditionalDrives = NewDriveConfigsFromRequest(createVM.AdditionalDrives)
+
+	metadata, err := NewMetadataConfigFromRequest(createVM.Metadata)
+	if err != nil {
+		return err
+	}
+	c.FcMetadata = metadata
+	c.FcCloudInit = NewCloudInitConfigFromRequest(createVM)
+	if createVM.MmdsVersion != "" {
+		c.FcMmdsVersion = strings.ToUpper(createVM.MmdsVersion)
 	}
 
 	c.Debug = createVM.Debug
 	c.CPU = createVM.VcpuCount
 	c.Mem = createVM.MemSizeMib
 	c.Smt = createVM.EnableSmt
+	c.FcBalloon = NewBalloonConfigFromRequest(createVM.Balloon)
+	c.FcNetworkInterfaces = NewNetworkInterfaceConfigsFromRequest(createVM)
+	c.IPAddress = c.FcNetworkInterfaces[0].IPAddress
+
+	c.FcDriveRateLimiters = map[string]*RateLimiterConfig{}
+	for driveID, rateLimiter := range createVM.DriveRateLimiters {
+		rateLimiter := rateLimiter
+		c.FcDriveRateLimiters[driveID] = NewRateLimiterConfigFromRequest(&rateLimiter)
+	}
+	c.FcEgressPolicy = NewEgressPolicyConfigFromRequest(createVM.EgressPolicy)
+	c.FcJailerLimits = NewJailerLimitsConfigFromRequest(createVM.Cgroup, createVM.ResourceLimits)
 
 	if err := c.Validate(); err != nil {
 		return err
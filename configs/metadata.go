@@ -5,31 +5,101 @@ import (
 	"fmt"
 )
 
-// MachineConfig provides machine configuration options.
+// MetadataMaxSizeBytes is the maximum size of the JSON encoded metadata.
+// Firecracker limits the MMDS contents to 51200 bytes by default, the rest is left to the generated keys.
+const MetadataMaxSizeBytes = 32768
+
+// MetadataReservedKeys are the MMDS top-level keys generated by the server.
+var MetadataReservedKeys = []string{"network", "latest"}
+
+// MetadataConfig provides the user metadata published in MMDS.
 type MetadataConfig struct {
-	Data string `json:"Data" description:"Data to pass to the VM"`
+	Data map[string]interface{} `json:"Data" description:"JSON object to pass to the VM"`
 }
 
-// NewMachineConfig returns a new instance of the configuration.
+// NewMetadataConfig returns a new instance of the configuration.
 func NewMetadataConfig() *MetadataConfig {
 	return &MetadataConfig{
-		Data:      "",
+		Data: map[string]interface{}{},
 	}
 }
 
-func (r *MetadataConfig) Serialize() (interface{}, error) {
+// NewMetadataConfigFromRequest parses the metadata of a request, which must be a JSON object.
+// An empty or null body is an empty object.
+func NewMetadataConfigFromRequest(raw json.RawMessage) (*MetadataConfig, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return NewMetadataConfig(), nil
+	}
+	if len(raw) > MetadataMaxSizeBytes {
+		return nil, fmt.Errorf("metadata cannot be larger than %d bytes", MetadataMaxSizeBytes)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("metadata must be a JSON object")
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	config := &MetadataConfig{
+		Data: data,
+	}
+	return config, config.Validate()
+}
 
-	jsonData, err := json.Marshal(r)
+// Validate validates the correctness of the configuration.
+func (r *MetadataConfig) Validate() error {
+	for _, key := range MetadataReservedKeys {
+		if _, ok := r.Data[key]; ok {
+			return fmt.Errorf("metadata key %s is reserved", key)
+		}
+	}
+	encoded, err := json.Marshal(r.Data)
 	if err != nil {
-		fmt.Println("Error marshaling to JSON:", err)
-		return nil, err
+		return fmt.Errorf("metadata cannot be encoded: %s", err)
 	}
+	if len(encoded) > MetadataMaxSizeBytes {
+		return fmt.Errorf("metadata cannot be larger than %d bytes", MetadataMaxSizeBytes)
+	}
+	return nil
+}
 
-	var validMetadata interface{}
+// Serialize returns a copy of the metadata object, the generated keys can be added to it.
+func (r *MetadataConfig) Serialize() map[string]interface{} {
+	result := make(map[string]interface{}, len(r.Data))
+	for key, value := range r.Data {
+		result[key] = value
+	}
+	return result
+}
 
-	if err := json.Unmarshal(jsonData, &validMetadata); err != nil {
-		return nil, fmt.Errorf("cannot parse from string to json the metadata: %v", err)
+// Merge returns the metadata with the patch applied following JSON merge patch, RFC 7386:
+// objects are merged recursively and null values remove keys.
+func (r *MetadataConfig) Merge(patch *MetadataConfig) *MetadataConfig {
+	return &MetadataConfig{
+		Data: mergePatch(r.Data, patch.Data),
 	}
+}
 
-	return validMetadata, nil
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(target))
+	for key, value := range target {
+		result[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		patchObject, ok := value.(map[string]interface{})
+		if !ok {
+			result[key] = value
+			continue
+		}
+		targetObject, ok := result[key].(map[string]interface{})
+		if !ok {
+			targetObject = map[string]interface{}{}
+		}
+		result[key] = mergePatch(targetObject, patchObject)
+	}
+	return result
 }
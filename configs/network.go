@@ -0,0 +1,195 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+)
+
+// IfNameMaxLength is the maximum length of a Linux network interface name.
+const IfNameMaxLength = 15
+
+// NetworkInterfaceConfig provides the configuration of a VM network interface attached to a CNI network.
+type NetworkInterfaceConfig struct {
+	CNINetworkName string               `json:"CniNetworkName" mapstructure:"CniNetworkName" description:"CNI network the interface is attached to"`
+	IfName         string               `json:"IfName" mapstructure:"IfName" description:"CNI interface name created in the VMM network namespace"`
+	AllowMMDS      bool                 `json:"AllowMMDS" mapstructure:"AllowMMDS" description:"If the MMDS is reachable through this interface"`
+	InRateLimiter  *RateLimiterConfig   `json:"InRateLimiter" mapstructure:"InRateLimiter" description:"Rate limiter for the traffic received by the guest"`
+	OutRateLimiter *RateLimiterConfig   `json:"OutRateLimiter" mapstructure:"OutRateLimiter" description:"Rate limiter for the traffic sent by the guest"`
+	IPAddress      string               `json:"IPAddress" mapstructure:"IPAddress" description:"IP address to request from the CNI IPAM; if not given, a new IP will be allocated"`
+	MacAddress     string               `json:"MacAddress" mapstructure:"MacAddress" description:"MAC address of the guest interface; if not given, a random one is used"`
+	PortMappings   []*PortMappingConfig `json:"PortMappings" mapstructure:"PortMappings" description:"Guest ports exposed on the host"`
+
+	// Result is set once the interface has been added to the CNI network.
+	Result *NetworkInterfaceResult `json:"-"`
+}
+
+// NetworkInterfaceResult represents the guest network configuration returned by CNI for an interface.
+type NetworkInterfaceResult struct {
+	TapName     string
+	HostIfName  string
+	MacAddress  string
+	IPAddr      net.IPNet
+	Gateway     net.IP
+	IPv6Addr    *net.IPNet
+	IPv6Gateway net.IP
+	Nameservers []string
+}
+
+// IPs returns the IPv4 address and, on dual-stack networks, the IPv6 address.
+func (r *NetworkInterfaceResult) IPs() []net.IP {
+	result := []net.IP{r.IPAddr.IP}
+	if r.IPv6Addr != nil {
+		result = append(result, r.IPv6Addr.IP)
+	}
+	return result
+}
+
+// NewNetworkInterfaceConfigsFromRequest returns the network interfaces of the request.
+// When the request has no interfaces list, a single interface is created on the cniNetworkName network.
+func NewNetworkInterfaceConfigsFromRequest(createVM *requests.CreateVMRequest) []*NetworkInterfaceConfig {
+	if len(createVM.NetworkInterfaces) == 0 {
+		return []*NetworkInterfaceConfig{
+			{
+				CNINetworkName: createVM.CniNetworkName,
+				IfName:         DefaultVethIfaceName + utils.RandStringBytes(11),
+				AllowMMDS:      true,
+				InRateLimiter:  NewRateLimiterConfigFromRequest(createVM.InRateLimiter),
+				OutRateLimiter: NewRateLimiterConfigFromRequest(createVM.OutRateLimiter),
+				IPAddress:      createVM.IPAddress,
+				MacAddress:     createVM.MacAddress,
+				PortMappings:   NewPortMappingConfigsFromRequest(createVM.PortMappings),
+			},
+		}
+	}
+
+	result := []*NetworkInterfaceConfig{}
+	for i, iface := range createVM.NetworkInterfaces {
+		ifName := iface.IfName
+		if ifName == "" {
+			ifName = DefaultVethIfaceName + utils.RandStringBytes(11)
+		}
+		// only the primary interface allows MMDS, unless told otherwise
+		allowMMDS := i == 0
+		if iface.AllowMmds != nil {
+			allowMMDS = *iface.AllowMmds
+		}
+		result = append(result, &NetworkInterfaceConfig{
+			CNINetworkName: iface.CniNetworkName,
+			IfName:         ifName,
+			AllowMMDS:      allowMMDS,
+			InRateLimiter:  NewRateLimiterConfigFromRequest(iface.InRateLimiter),
+			OutRateLimiter: NewRateLimiterConfigFromRequest(iface.OutRateLimiter),
+			IPAddress:      iface.IPAddress,
+			MacAddress:     iface.MacAddress,
+			PortMappings:   NewPortMappingConfigsFromRequest(iface.PortMappings),
+		})
+	}
+	return result
+}
+
+// Validate validates the correctness of the configuration.
+func (c *NetworkInterfaceConfig) Validate() error {
+	if c.CNINetworkName == "" {
+		return fmt.Errorf("cni network of network interface cannot be empty")
+	}
+	if c.IfName == "" || len(c.IfName) > IfNameMaxLength {
+		return fmt.Errorf("ifName of network interface must have between 1 and %d characters", IfNameMaxLength)
+	}
+	if c.IPAddress != "" {
+		if parsedIP := net.ParseIP(c.IPAddress); parsedIP == nil || parsedIP.To4() == nil {
+			return fmt.Errorf("ipAddress of network interface is not an IPv4 address")
+		}
+	}
+	if c.MacAddress != "" {
+		if _, err := net.ParseMAC(c.MacAddress); err != nil {
+			return fmt.Errorf("macAddress of network interface is invalid: %s", err)
+		}
+	}
+	for _, portMapping := range c.PortMappings {
+		if err := portMapping.Validate(); err != nil {
+			return fmt.Errorf("invalid port mapping: %s", err)
+		}
+	}
+	if c.InRateLimiter != nil {
+		if err := c.InRateLimiter.Validate(); err != nil {
+			return fmt.Errorf("invalid inRateLimiter: %s", err)
+		}
+	}
+	if c.OutRateLimiter != nil {
+		if err := c.OutRateLimiter.Validate(); err != nil {
+			return fmt.Errorf("invalid outRateLimiter: %s", err)
+		}
+	}
+	return nil
+}
+
+func validateNetworkInterfaces(interfaces []*NetworkInterfaceConfig) error {
+	if len(interfaces) == 0 {
+		return fmt.Errorf("at least one network interface is required")
+	}
+	ifNames := map[string]bool{}
+	macAddresses := map[string]bool{}
+	hostPorts := []*PortMappingConfig{}
+	for i, iface := range interfaces {
+		if err := iface.Validate(); err != nil {
+			return fmt.Errorf("network interface %d: %s", i+1, err)
+		}
+		if ifNames[iface.IfName] {
+			return fmt.Errorf("network interface %d: ifName %s is used more than once", i+1, iface.IfName)
+		}
+		ifNames[iface.IfName] = true
+		if iface.MacAddress != "" {
+			if macAddresses[iface.MacAddress] {
+				return fmt.Errorf("network interface %d: macAddress %s is used more than once", i+1, iface.MacAddress)
+			}
+			macAddresses[iface.MacAddress] = true
+		}
+		for _, portMapping := range iface.PortMappings {
+			for _, other := range hostPorts {
+				if portMapping.Conflicts(other) {
+					return fmt.Errorf("network interface %d: host port %s conflicts with %s", i+1, portMapping.Key(), other.Key())
+				}
+			}
+			hostPorts = append(hostPorts, portMapping)
+		}
+	}
+	return nil
+}
+
+// NetworkMetadata returns the guest network configuration published in MMDS under the network key.
+// The ip= kernel argument only configures the IPv4 address of the primary interface, the guest
+// reads the IPv6 addresses and the secondary interfaces from here. Devices are named in order, eth0 first.
+func NetworkMetadata(interfaces []*NetworkInterfaceConfig) map[string]interface{} {
+	ifaces := []map[string]interface{}{}
+	nameservers := []string{}
+	for i, iface := range interfaces {
+		if iface.Result == nil {
+			continue
+		}
+		if i == 0 {
+			nameservers = iface.Result.Nameservers
+		}
+		ifaceMetadata := map[string]interface{}{
+			"device":  fmt.Sprintf("eth%d", i),
+			"mac":     iface.Result.MacAddress,
+			"network": iface.CNINetworkName,
+			"ipv4": map[string]string{
+				"address": iface.Result.IPAddr.String(),
+				"gateway": iface.Result.Gateway.String(),
+			},
+		}
+		if iface.Result.IPv6Addr != nil {
+			ifaceMetadata["ipv6"] = map[string]string{
+				"address": iface.Result.IPv6Addr.String(),
+				"gateway": iface.Result.IPv6Gateway.String(),
+			}
+		}
+		ifaces = append(ifaces, ifaceMetadata)
+	}
+	return map[string]interface{}{
+		"interfaces":  ifaces,
+		"nameservers": nameservers,
+	}
+}
@@ -0,0 +1,74 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"open-fire/dtos/requests"
+	"strconv"
+	"strings"
+)
+
+const (
+	PortMappingProtocolTCP = "tcp"
+	PortMappingProtocolUDP = "udp"
+)
+
+// PortMappingConfig exposes a guest port on the host through the CNI portmap plugin.
+type PortMappingConfig struct {
+	HostPort  int    `json:"HostPort" mapstructure:"HostPort" description:"Port on the host"`
+	GuestPort int    `json:"GuestPort" mapstructure:"GuestPort" description:"Port in the guest"`
+	Protocol  string `json:"Protocol" mapstructure:"Protocol" description:"tcp or udp"`
+	HostIP    string `json:"HostIP" mapstructure:"HostIP" description:"Host IPv4 or IPv6 address to map, all addresses of both families if empty"`
+}
+
+// NewPortMappingConfigsFromRequest returns the port mappings of the request, the protocol defaults to tcp.
+func NewPortMappingConfigsFromRequest(portMappings []requests.PortMappingRequest) []*PortMappingConfig {
+	result := []*PortMappingConfig{}
+	for _, portMapping := range portMappings {
+		protocol := strings.ToLower(portMapping.Protocol)
+		if protocol == "" {
+			protocol = PortMappingProtocolTCP
+		}
+		result = append(result, &PortMappingConfig{
+			HostPort:  portMapping.HostPort,
+			GuestPort: portMapping.GuestPort,
+			Protocol:  protocol,
+			HostIP:    portMapping.HostIP,
+		})
+	}
+	return result
+}
+
+// Validate validates the correctness of the configuration.
+func (c *PortMappingConfig) Validate() error {
+	if c.HostPort < 1 || c.HostPort > 65535 {
+		return fmt.Errorf("hostPort must be between 1 and 65535")
+	}
+	if c.GuestPort < 1 || c.GuestPort > 65535 {
+		return fmt.Errorf("guestPort must be between 1 and 65535")
+	}
+	if c.Protocol != PortMappingProtocolTCP && c.Protocol != PortMappingProtocolUDP {
+		return fmt.Errorf("protocol must be tcp or udp")
+	}
+	if c.HostIP != "" && net.ParseIP(c.HostIP) == nil {
+		return fmt.Errorf("hostIP is not an IP address")
+	}
+	return nil
+}
+
+// Key identifies the host side of the port mapping, as hostIP:port/protocol, the host IP being empty for all addresses.
+func (c *PortMappingConfig) Key() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(c.HostIP, strconv.Itoa(c.HostPort)), c.Protocol)
+}
+
+// Conflicts returns true if both mappings map the same host port and protocol on a common address.
+// An empty host IP maps all the addresses, so it conflicts with every host IP.
+func (c *PortMappingConfig) Conflicts(other *PortMappingConfig) bool {
+	if c.HostPort != other.HostPort || c.Protocol != other.Protocol {
+		return false
+	}
+	if c.HostIP == "" || other.HostIP == "" {
+		return true
+	}
+	return net.ParseIP(c.HostIP).Equal(net.ParseIP(other.HostIP))
+}
@@ -0,0 +1,104 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+)
+
+// TokenBucketConfig provides the configuration of a Firecracker token bucket.
+type TokenBucketConfig struct {
+	Size         int64 `json:"Size" mapstructure:"Size" description:"Total number of tokens the bucket can hold"`
+	OneTimeBurst int64 `json:"OneTimeBurst" mapstructure:"OneTimeBurst" description:"Initial size of a token bucket, consumed once before the refill rate applies"`
+	RefillTimeMs int64 `json:"RefillTimeMs" mapstructure:"RefillTimeMs" description:"Amount of milliseconds it takes for the bucket to refill"`
+}
+
+// RateLimiterConfig provides the bandwidth and ops token buckets of a device.
+type RateLimiterConfig struct {
+	Bandwidth *TokenBucketConfig `json:"Bandwidth" mapstructure:"Bandwidth" description:"Token bucket with bytes as tokens"`
+	Ops       *TokenBucketConfig `json:"Ops" mapstructure:"Ops" description:"Token bucket with operations as tokens"`
+}
+
+// NewRateLimiterConfigFromRequest returns a rate limiter for the request, nil if no rate limiter was requested.
+func NewRateLimiterConfigFromRequest(rateLimiter *requests.RateLimiterRequest) *RateLimiterConfig {
+	if rateLimiter == nil {
+		return nil
+	}
+	return &RateLimiterConfig{
+		Bandwidth: newTokenBucketConfigFromRequest(rateLimiter.Bandwidth),
+		Ops:       newTokenBucketConfigFromRequest(rateLimiter.Ops),
+	}
+}
+
+func newTokenBucketConfigFromRequest(tokenBucket *requests.TokenBucketRequest) *TokenBucketConfig {
+	if tokenBucket == nil {
+		return nil
+	}
+	return &TokenBucketConfig{
+		Size:         tokenBucket.Size,
+		OneTimeBurst: tokenBucket.OneTimeBurst,
+		RefillTimeMs: tokenBucket.RefillTimeMs,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *RateLimiterConfig) Validate() error {
+	if c.Bandwidth == nil && c.Ops == nil {
+		return fmt.Errorf("rate limiter must have a bandwidth or an ops token bucket")
+	}
+	if c.Bandwidth != nil {
+		if err := c.Bandwidth.Validate(); err != nil {
+			return fmt.Errorf("invalid bandwidth token bucket: %s", err)
+		}
+	}
+	if c.Ops != nil {
+		if err := c.Ops.Validate(); err != nil {
+			return fmt.Errorf("invalid ops token bucket: %s", err)
+		}
+	}
+	return nil
+}
+
+// Validate validates the correctness of the configuration.
+func (c *TokenBucketConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("size cannot be negative")
+	}
+	if c.OneTimeBurst < 0 {
+		return fmt.Errorf("oneTimeBurst cannot be negative")
+	}
+	if c.RefillTimeMs < 0 {
+		return fmt.Errorf("refillTimeMs cannot be negative")
+	}
+	if c.Size > 0 && c.RefillTimeMs == 0 {
+		return fmt.Errorf("refillTimeMs is required when size is set")
+	}
+	return nil
+}
+
+// ToModel converts the configuration to the Firecracker model, nil configuration returns nil.
+func (c *RateLimiterConfig) ToModel() *models.RateLimiter {
+	if c == nil {
+		return nil
+	}
+	return &models.RateLimiter{
+		Bandwidth: c.Bandwidth.toModel(),
+		Ops:       c.Ops.toModel(),
+	}
+}
+
+func (c *TokenBucketConfig) toModel() *models.TokenBucket {
+	if c == nil {
+		return nil
+	}
+	tokenBucket := &models.TokenBucket{
+		Size:       firecracker.Int64(c.Size),
+		RefillTime: firecracker.Int64(c.RefillTimeMs),
+	}
+	if c.OneTimeBurst > 0 {
+		tokenBucket.OneTimeBurst = firecracker.Int64(c.OneTimeBurst)
+	}
+	return tokenBucket
+}
@@ -0,0 +1,94 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/utils"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+)
+
+const (
+	// SeccompFilterDefault selects the seccomp filters built in Firecracker.
+	SeccompFilterDefault = "default"
+	// SeccompFilterNone disables seccomp, only meant for debugging.
+	SeccompFilterNone = "none"
+	// SeccompFilterJailPath is the path in the jail of the compiled custom filter, read by Firecracker once jailed.
+	SeccompFilterJailPath = "/seccomp.bpf"
+)
+
+// SeccompFilterThreads are the Firecracker threads a custom filter must have a filter for.
+var SeccompFilterThreads = []string{"vmm", "api", "vcpu"}
+
+// seccompFilterNameRegexp matches the names of the custom seccomp filters, the file names of the filters directory without .json.
+var seccompFilterNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}$`)
+
+// SeccompFiltersConfig provides the custom seccomp filters configuration options.
+type SeccompFiltersConfig struct {
+	Dir      string `json:"Dir" mapstructure:"Dir" description:"Directory of the custom seccomp filter definitions and their compiled filters, SECCOMP_FILTERS_DIR"`
+	Compiler string `json:"Compiler" mapstructure:"Compiler" description:"Path to the seccompiler-bin binary of the Firecracker release, SECCOMPILER_PATH"`
+	Default  string `json:"Default" mapstructure:"Default" description:"Filter of the VMs not selecting one: default, none or a custom filter, SECCOMP_FILTER"`
+}
+
+// NewSeccompFiltersConfig returns a new instance of the configuration, read from the environment.
+func NewSeccompFiltersConfig() *SeccompFiltersConfig {
+	return &SeccompFiltersConfig{
+		Dir:      utils.GetenvOrDefault("SECCOMP_FILTERS_DIR", "/srv/seccomp-filters"),
+		Compiler: utils.GetenvOrDefault("SECCOMPILER_PATH", "/usr/bin/seccompiler-bin"),
+		Default:  normalizeSeccompFilter(utils.GetenvOrDefault("SECCOMP_FILTER", SeccompFilterDefault)),
+	}
+}
+
+// FilterPath returns the path of the JSON definition of the custom seccomp filter with the given name.
+func (c *SeccompFiltersConfig) FilterPath(name string) (string, error) {
+	if err := ValidateCustomSeccompFilterName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, name+".json"), nil
+}
+
+// BPFPath returns the path of the compiled custom seccomp filter with the given name.
+func (c *SeccompFiltersConfig) BPFPath(name string) (string, error) {
+	if err := ValidateCustomSeccompFilterName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, name+".bpf"), nil
+}
+
+// ValidateCustomSeccompFilterName returns an error if the name cannot be used for a custom seccomp filter.
+func ValidateCustomSeccompFilterName(name string) error {
+	if !seccompFilterNameRegexp.MatchString(name) {
+		return fmt.Errorf("seccomp filter name must be up to 64 letters, digits, '.', '_' or '-', starting with a letter or a digit")
+	}
+	if normalizeSeccompFilter(name) == SeccompFilterDefault || normalizeSeccompFilter(name) == SeccompFilterNone {
+		return fmt.Errorf("seccomp filter name %s is reserved", name)
+	}
+	return nil
+}
+
+// IsCustomSeccompFilter returns true if the filter is not the Firecracker one nor disables seccomp.
+func IsCustomSeccompFilter(filter string) bool {
+	return filter != "" && filter != SeccompFilterDefault && filter != SeccompFilterNone
+}
+
+// SeccompConfig returns the seccomp settings of the machine, the custom filter being copied in the jail at start.
+func (c *MachineConfig) SeccompConfig() firecracker.SeccompConfig {
+	switch {
+	case c.FcSeccompFilter == SeccompFilterNone:
+		return firecracker.SeccompConfig{Enabled: false}
+	case IsCustomSeccompFilter(c.FcSeccompFilter):
+		return firecracker.SeccompConfig{Enabled: true, Filter: SeccompFilterJailPath}
+	default:
+		return firecracker.SeccompConfig{Enabled: true}
+	}
+}
+
+// normalizeSeccompFilter lowercases the reserved filter names, the custom filter names are kept as is.
+func normalizeSeccompFilter(filter string) string {
+	if strings.EqualFold(filter, SeccompFilterDefault) || strings.EqualFold(filter, SeccompFilterNone) {
+		return strings.ToLower(filter)
+	}
+	return filter
+}
@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"fmt"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	// VolumeMinSizeMib is the minimum size of a volume, mkfs.ext4 needs room for its metadata.
+	VolumeMinSizeMib = 8
+	// VolumeMaxSizeMib is the maximum size of a volume.
+	VolumeMaxSizeMib = 1 << 22
+)
+
+// volumeNameRegexp matches the volume names, the file names of the volumes directory.
+var volumeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,127}$`)
+
+// VolumesConfig provides the persistent volumes configuration options.
+type VolumesConfig struct {
+	Dir string `json:"Dir" mapstructure:"Dir" description:"Directory of the volume files, VOLUMES_DIR"`
+}
+
+// NewVolumesConfig returns a new instance of the configuration, read from the environment.
+func NewVolumesConfig() *VolumesConfig {
+	return &VolumesConfig{
+		Dir: utils.GetenvOrDefault("VOLUMES_DIR", "/srv/volumes"),
+	}
+}
+
+// VolumePath returns the path of the volume with the given name.
+func (c *VolumesConfig) VolumePath(name string) (string, error) {
+	if !volumeNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("volume name %q must be a file name of letters, digits, '.', '_' or '-'", name)
+	}
+	return filepath.Join(c.Dir, name), nil
+}
+
+// VolumeName returns the name of the volume backed by the path, false if the path is not in the volumes directory.
+func (c *VolumesConfig) VolumeName(path string) (string, bool) {
+	dir, name := filepath.Split(filepath.Clean(path))
+	if filepath.Clean(dir) != filepath.Clean(c.Dir) || !volumeNameRegexp.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// VolumeConfig provides the configuration of a volume to create.
+type VolumeConfig struct {
+	Name    string `json:"Name" mapstructure:"Name" description:"Name of the volume, the file name in the volumes directory"`
+	SizeMib int    `json:"SizeMib" mapstructure:"SizeMib" description:"Size of the volume"`
+}
+
+// NewVolumeConfigFromRequest returns the volume of the request.
+func NewVolumeConfigFromRequest(volume *requests.CreateVolumeRequest) *VolumeConfig {
+	return &VolumeConfig{
+		Name:    volume.Name,
+		SizeMib: volume.SizeMib,
+	}
+}
+
+// Validate validates the correctness of the configuration.
+func (c *VolumeConfig) Validate() error {
+	if !volumeNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("name %q must be a file name of letters, digits, '.', '_' or '-'", c.Name)
+	}
+	return ValidateVolumeSize(c.SizeMib)
+}
+
+// ValidateVolumeSize returns an error if the size is out of the volume size range.
+func ValidateVolumeSize(sizeMib int) error {
+	if sizeMib < VolumeMinSizeMib || sizeMib > VolumeMaxSizeMib {
+		return fmt.Errorf("sizeMib must be between %d and %d", VolumeMinSizeMib, VolumeMaxSizeMib)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"strings"
+)
+
+// cpuTemplateMaxBytes is the maximum size of an uploaded custom CPU template.
+const cpuTemplateMaxBytes = 1 << 20
+
+// cpuTemplatesRequestHandler dispatches the requests made to /v1/cpu-templates, /v1/cpu-templates/{name},
+// /v1/cpu-templates/{name}/generate and /v1/cpu-templates/{name}/verify.
+func cpuTemplatesRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/cpu-templates"), "/"), "/")
+	name, action := segments[0], ""
+	if len(segments) > 1 {
+		action = segments[1]
+	}
+
+	switch {
+	case len(segments) > 2:
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	case name == "" && r.Method == http.MethodGet:
+		listCPUTemplatesRequestHandler(w, r)
+	case name != "" && action == "" && r.Method == http.MethodGet:
+		getCPUTemplateRequestHandler(w, r, name)
+	case name != "" && action == "" && r.Method == http.MethodPut:
+		putCPUTemplateRequestHandler(w, r, name)
+	case name != "" && action == "" && r.Method == http.MethodDelete:
+		deleteCPUTemplateRequestHandler(w, r, name)
+	case (action == "generate" || action == "verify") && r.Method == http.MethodPost:
+		cpuTemplateHelperRequestHandler(w, r, name, action)
+	case name == "" || action == "" || action == "generate" || action == "verify":
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+	default:
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	}
+}
+
+func listCPUTemplatesRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	templates, err := fcManager.ListCPUTemplates()
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListCPUTemplatesResponse{
+		Static: configs.StaticCPUTemplates(),
+		Custom: []response.CPUTemplateResponse{},
+	}
+	for _, template := range templates {
+		resp.Custom = append(resp.Custom, cpuTemplateResponse(template))
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+// getCPUTemplateRequestHandler writes the custom CPU template file as is.
+func getCPUTemplateRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	_, contents, err := fcManager.GetCPUTemplate(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(contents)
+}
+
+// putCPUTemplateRequestHandler creates or replaces a custom CPU template with the JSON body, in the Firecracker format.
+func putCPUTemplateRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	contents, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cpuTemplateMaxBytes))
+	if err != nil {
+		writeErrorResponse(w, 422, "failed to read body "+err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	template, created, err := fcManager.PutCPUTemplate(name, contents)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	statusCode := 200
+	if created {
+		statusCode = 201
+	}
+	resp := cpuTemplateResponse(template)
+	writeJSONResponse(w, statusCode, &resp)
+}
+
+func deleteCPUTemplateRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.DeleteCPUTemplate(name); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// cpuTemplateHelperRequestHandler runs cpu-template-helper to generate the template from the host CPU,
+// or to verify the template is applied as intended on the host.
+func cpuTemplateHelperRequestHandler(w http.ResponseWriter, r *http.Request, name, action string) {
+
+	var req requests.CPUTemplateHelperRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	helperConfig := configs.NewCPUTemplateHelperConfigFromRequest(&req)
+	if err := helperConfig.Validate(); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if action == "generate" {
+		template, output, err := fcManager.GenerateCPUTemplate(name, helperConfig)
+		if err != nil {
+			errorMsg := err.Error()
+			if output != "" {
+				errorMsg += ": " + strings.TrimSpace(output)
+			}
+			writeErrorResponse(w, managerErrorStatus(err), errorMsg)
+			return
+		}
+		resp := cpuTemplateResponse(template)
+		writeJSONResponse(w, 201, &resp)
+		return
+	}
+
+	verified, output, err := fcManager.VerifyCPUTemplate(name, helperConfig)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.CPUTemplateVerifyResponse{
+		Verified: verified,
+		Output:   output,
+	}
+	writeJSONResponse(w, 200, &resp)
+}
+
+func cpuTemplateResponse(template *managers.CPUTemplateInfo) response.CPUTemplateResponse {
+	return response.CPUTemplateResponse{
+		Name:   template.Name,
+		Arch:   template.Arch,
+		Path:   template.Path,
+		UsedBy: template.UsedBy,
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"os"
+	"strings"
+)
+
+// disksRequestHandler dispatches the requests made to /v1/disks, /v1/disks/{name}, /v1/disks/{name}/mount,
+// /v1/disks/{name}/unmount and /v1/disks/{name}/files/{path}.
+func disksRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	segments := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/disks"), "/"), "/", 3)
+	name, action := segments[0], ""
+	if len(segments) > 1 {
+		action = segments[1]
+	}
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		listDisksRequestHandler(w, r)
+	case name != "" && action == "" && r.Method == http.MethodGet:
+		getDiskRequestHandler(w, r, name)
+	case (action == "mount" || action == "unmount") && len(segments) == 2 && r.Method == http.MethodPost:
+		if action == "mount" {
+			mountDiskRequestHandler(w, r, name)
+		} else {
+			unmountDiskRequestHandler(w, r, name)
+		}
+	case action == "files" && r.Method == http.MethodGet:
+		path := "/"
+		if len(segments) == 3 {
+			path = segments[2]
+		}
+		diskFilesRequestHandler(w, r, name, path)
+	case name == "" || action == "" || action == "mount" || action == "unmount" || action == "files":
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+	default:
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	}
+}
+
+func listDisksRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	disks, err := fcManager.ListDisks()
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListDisksResponse{
+		Disks: []response.DiskResponse{},
+	}
+	for _, disk := range disks {
+		resp.Disks = append(resp.Disks, diskResponse(disk))
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+func getDiskRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	disk, err := fcManager.GetDisk(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := diskResponse(disk)
+	writeJSONResponse(w, 200, &resp)
+}
+
+func mountDiskRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	disk, err := fcManager.MountDisk(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.MountDiskResponse{
+		MountDir: disk.MountDir,
+	}
+	writeJSONResponse(w, 200, &resp)
+}
+
+func unmountDiskRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.UnmountDisk(name); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// diskFilesRequestHandler lists a directory of a mounted disk, or downloads a regular file.
+func diskFilesRequestHandler(w http.ResponseWriter, r *http.Request, name, path string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	hostPath, info, err := fcManager.StatDiskFile(name, path)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	if info.Mode().IsRegular() {
+		file, err := os.Open(hostPath)
+		if err != nil {
+			writeErrorResponse(w, 500, err.Error())
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(info.Name(), `"`, "")+`"`)
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+		return
+	}
+
+	files, err := fcManager.ListDiskFiles(name, path)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListDiskFilesResponse{
+		Path:  "/" + strings.Trim(path, "/"),
+		Files: []response.DiskFileResponse{},
+	}
+	for _, file := range files {
+		resp.Files = append(resp.Files, response.DiskFileResponse{
+			Name:       file.Name,
+			Type:       file.Type,
+			SizeBytes:  file.SizeBytes,
+			Mode:       file.Mode.String(),
+			ModTime:    file.ModTime,
+			LinkTarget: file.LinkTarget,
+		})
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+func diskResponse(disk *managers.DiskInfo) response.DiskResponse {
+	return response.DiskResponse{
+		Name:        disk.Name,
+		Path:        disk.Path,
+		SizeBytes:   disk.SizeBytes,
+		Mounted:     disk.Mounted,
+		MountDir:    disk.MountDir,
+		AttachedVMs: disk.AttachedVMs,
+	}
+}
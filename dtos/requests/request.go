@@ -1,20 +1,143 @@
 package requests
 
-type MetadataRequest struct {
-	Data string `json:"Data" description:"Data to pass to the VM"`
-}
+import "encoding/json"
 
 type CreateVMRequest struct {
-	KernelPath       string          `json:"kernelPath"`
-	RootDrivePath    string          `json:"rootDrivePath"`
-	CniNetworkName   string          `json:"cniNetworkName"`
-	AdditionalDrives string          `json:"additionalDrives"`
-	Metadata         MetadataRequest `json:"metadata"`
-	Debug            bool            `json:"debug"`
-	VcpuCount        int64           `json:"vCpuCount"`
-	MemSizeMib       int64           `json:"memSizeMib"`
-	EnableSmt        bool            `json:"enableSmt"`
-	JailerChrootBase string          `json:"jailerChrootBase"`
+	Name                string                        `json:"name"`
+	KernelPath          string                        `json:"kernelPath"`
+	RootDrivePath       string                        `json:"rootDrivePath"`
+	PersistRootfs       bool                          `json:"persistRootfs"`
+	RootfsSizeMib       int                           `json:"rootfsSizeMib"`
+	Kernel              string                        `json:"kernel"`
+	InitrdPath          string                        `json:"initrdPath"`
+	Initrd              string                        `json:"initrd"`
+	Image               string                        `json:"image"`
+	KernelArgs          string                        `json:"kernelArgs"`
+	CniNetworkName      string                        `json:"cniNetworkName"`
+	AdditionalDrives    []DriveRequest                `json:"additionalDrives"`
+	UserData            string                        `json:"userData"`
+	SSHAuthorizedKeys   []string                      `json:"sshAuthorizedKeys"`
+	CloudInitDatasource string                        `json:"cloudInitDatasource"`
+	MmdsVersion         string                        `json:"mmdsVersion"`
+	Metadata            json.RawMessage               `json:"metadata"`
+	Debug               bool                          `json:"debug"`
+	VcpuCount           int64                         `json:"vCpuCount"`
+	MemSizeMib          int64                         `json:"memSizeMib"`
+	EnableSmt           bool                          `json:"enableSmt"`
+	CPUTemplate         string                        `json:"cpuTemplate"`
+	SeccompFilter       string                        `json:"seccompFilter"`
+	JailerChrootBase    string                        `json:"jailerChrootBase"`
+	Balloon             *BalloonRequest               `json:"balloon"`
+	InRateLimiter       *RateLimiterRequest           `json:"inRateLimiter"`
+	OutRateLimiter      *RateLimiterRequest           `json:"outRateLimiter"`
+	DriveRateLimiters   map[string]RateLimiterRequest `json:"driveRateLimiters"`
+	NetworkInterfaces   []NetworkInterfaceRequest     `json:"networkInterfaces"`
+	IPAddress           string                        `json:"ipAddress"`
+	MacAddress          string                        `json:"macAddress"`
+	PortMappings        []PortMappingRequest          `json:"portMappings"`
+	EgressPolicy        *EgressPolicyRequest          `json:"egressPolicy"`
+	Cgroup              *CgroupRequest                `json:"cgroup"`
+	ResourceLimits      *ResourceLimitsRequest        `json:"resourceLimits"`
+}
+
+type CgroupRequest struct {
+	CPUMax     string   `json:"cpuMax"`
+	CpusetCpus string   `json:"cpusetCpus"`
+	MemoryMax  string   `json:"memoryMax"`
+	IOMax      []string `json:"ioMax"`
+}
+
+type ResourceLimitsRequest struct {
+	Fsize  int64 `json:"fsize"`
+	NoFile int64 `json:"noFile"`
+}
+
+type DriveRequest struct {
+	Path        string              `json:"path"`
+	Volume      string              `json:"volume"`
+	ReadOnly    bool                `json:"readOnly"`
+	DriveID     string              `json:"driveId"`
+	Partuuid    string              `json:"partuuid"`
+	CacheType   string              `json:"cacheType"`
+	IoEngine    string              `json:"ioEngine"`
+	RateLimiter *RateLimiterRequest `json:"rateLimiter"`
+}
+
+type EgressPolicyRequest struct {
+	Mode  string              `json:"mode"`
+	Allow []EgressRuleRequest `json:"allow"`
+}
+
+type EgressRuleRequest struct {
+	CIDR     string `json:"cidr"`
+	Protocol string `json:"protocol"`
+	Ports    []int  `json:"ports"`
+}
+
+type PortMappingRequest struct {
+	HostPort  int    `json:"hostPort"`
+	GuestPort int    `json:"guestPort"`
+	Protocol  string `json:"protocol"`
+	HostIP    string `json:"hostIP"`
+}
+
+type NetworkInterfaceRequest struct {
+	CniNetworkName string               `json:"cniNetworkName"`
+	IfName         string               `json:"ifName"`
+	AllowMmds      *bool                `json:"allowMmds"`
+	InRateLimiter  *RateLimiterRequest  `json:"inRateLimiter"`
+	OutRateLimiter *RateLimiterRequest  `json:"outRateLimiter"`
+	IPAddress      string               `json:"ipAddress"`
+	MacAddress     string               `json:"macAddress"`
+	PortMappings   []PortMappingRequest `json:"portMappings"`
+}
+
+type TokenBucketRequest struct {
+	Size         int64 `json:"size"`
+	OneTimeBurst int64 `json:"oneTimeBurst"`
+	RefillTimeMs int64 `json:"refillTimeMs"`
+}
+
+type RateLimiterRequest struct {
+	Bandwidth *TokenBucketRequest `json:"bandwidth"`
+	Ops       *TokenBucketRequest `json:"ops"`
+}
+
+type UpdateNetworkInterfaceRequest struct {
+	InRateLimiter  *RateLimiterRequest `json:"inRateLimiter"`
+	OutRateLimiter *RateLimiterRequest `json:"outRateLimiter"`
+}
+
+type CreateVolumeRequest struct {
+	Name    string `json:"name"`
+	SizeMib int    `json:"sizeMib"`
+}
+
+type ResizeVolumeRequest struct {
+	SizeMib int `json:"sizeMib"`
+}
+
+type CPUTemplateHelperRequest struct {
+	KernelPath    string `json:"kernelPath"`
+	RootDrivePath string `json:"rootDrivePath"`
+	VcpuCount     int64  `json:"vCpuCount"`
+	MemSizeMib    int64  `json:"memSizeMib"`
+}
+
+type UpdateDriveRequest struct {
+	PathOnHost  string              `json:"pathOnHost"`
+	RateLimiter *RateLimiterRequest `json:"rateLimiter"`
+}
+
+type BalloonRequest struct {
+	AmountMib             int64 `json:"amountMib"`
+	DeflateOnOom          bool  `json:"deflateOnOom"`
+	StatsPollingIntervalS int64 `json:"statsPollingIntervalS"`
+}
+
+type UpdateBalloonRequest struct {
+	AmountMib             *int64 `json:"amountMib"`
+	StatsPollingIntervalS *int64 `json:"statsPollingIntervalS"`
 }
 
 type StopVMRequest struct {
@@ -24,6 +147,28 @@ type StopVMRequest struct {
 	JailerChrootBase string `json:"jailerChrootBase"`
 }
 
-type MountDiskRequest struct {
-	DiskName string `json:"diskName"`
+type CreateNetworkRequest struct {
+	Name       string `json:"name"`
+	Bridge     string `json:"bridge"`
+	Subnet     string `json:"subnet"`
+	IPv6Subnet string `json:"ipv6Subnet"`
+	Masquerade *bool  `json:"masquerade"`
+	Firewall   *bool  `json:"firewall"`
+}
+
+type CatalogEntryRequest struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Path        string `json:"path"`
+	Arch        string `json:"arch"`
+	Description string `json:"description"`
+}
+
+type BuildImageRequest struct {
+	ArchivePath string `json:"archivePath"`
+	Tag         string `json:"tag"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	SizeMib     int    `json:"sizeMib"`
 }
@@ -1,9 +1,32 @@
 package response
 
 type CreateVMResponse struct {
-	IP    string `json:"ip"`
-	PID   int    `json:"pid"`
-	VMMiD string `json:"vmId"`
+	IP                string                     `json:"ip"`
+	IPv6              string                     `json:"ipv6,omitempty"`
+	PID               int                        `json:"pid"`
+	VMMiD             string                     `json:"vmId"`
+	Name              string                     `json:"name,omitempty"`
+	NetworkInterfaces []NetworkInterfaceResponse `json:"networkInterfaces"`
+}
+
+type NetworkInterfaceResponse struct {
+	IfaceID        string                `json:"ifaceId"`
+	CniNetworkName string                `json:"cniNetworkName"`
+	IfName         string                `json:"ifName"`
+	IP             string                `json:"ip"`
+	Mac            string                `json:"mac"`
+	Gateway        string                `json:"gateway"`
+	IPv6           string                `json:"ipv6,omitempty"`
+	IPv6Gateway    string                `json:"ipv6Gateway,omitempty"`
+	AllowMmds      bool                  `json:"allowMmds"`
+	PortMappings   []PortMappingResponse `json:"portMappings"`
+}
+
+type PortMappingResponse struct {
+	HostPort  int    `json:"hostPort"`
+	GuestPort int    `json:"guestPort"`
+	Protocol  string `json:"protocol"`
+	HostIP    string `json:"hostIP,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -13,3 +36,129 @@ type ErrorResponse struct {
 type MountDiskResponse struct {
 	MountDir string `json:"mountDir"`
 }
+
+type DiskResponse struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	SizeBytes   int64    `json:"sizeBytes"`
+	Mounted     bool     `json:"mounted"`
+	MountDir    string   `json:"mountDir,omitempty"`
+	AttachedVMs []string `json:"attachedVms"`
+}
+
+type ListDisksResponse struct {
+	Disks []DiskResponse `json:"disks"`
+}
+
+type VolumeResponse struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	SizeMib     int64    `json:"sizeMib"`
+	AttachedVMs []string `json:"attachedVms"`
+	ReadWrite   bool     `json:"readWrite"`
+}
+
+type ListVolumesResponse struct {
+	Volumes []VolumeResponse `json:"volumes"`
+}
+
+type DiskFileResponse struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	Mode       string `json:"mode"`
+	ModTime    int64  `json:"modTime"`
+	LinkTarget string `json:"linkTarget,omitempty"`
+}
+
+type ListDiskFilesResponse struct {
+	Path  string             `json:"path"`
+	Files []DiskFileResponse `json:"files"`
+}
+
+type BalloonStatsResponse struct {
+	TargetPages        int64 `json:"targetPages"`
+	ActualPages        int64 `json:"actualPages"`
+	TargetMib          int64 `json:"targetMib"`
+	ActualMib          int64 `json:"actualMib"`
+	SwapIn             int64 `json:"swapIn"`
+	SwapOut            int64 `json:"swapOut"`
+	MajorFaults        int64 `json:"majorFaults"`
+	MinorFaults        int64 `json:"minorFaults"`
+	FreeMemory         int64 `json:"freeMemory"`
+	TotalMemory        int64 `json:"totalMemory"`
+	AvailableMemory    int64 `json:"availableMemory"`
+	DiskCaches         int64 `json:"diskCaches"`
+	HugetlbAllocations int64 `json:"hugetlbAllocations"`
+	HugetlbFailures    int64 `json:"hugetlbFailures"`
+}
+
+type NetworkResponse struct {
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	Bridge      string   `json:"bridge"`
+	Subnets     []string `json:"subnets"`
+	Masquerade  bool     `json:"masquerade"`
+	Plugins     []string `json:"plugins"`
+	AttachedVMs []string `json:"attachedVms"`
+}
+
+type ListNetworksResponse struct {
+	Networks []NetworkResponse `json:"networks"`
+}
+
+type CatalogEntryResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version,omitempty"`
+	Arch        string   `json:"arch"`
+	Description string   `json:"description,omitempty"`
+	Path        string   `json:"path"`
+	SHA256      string   `json:"sha256"`
+	SizeBytes   int64    `json:"sizeBytes"`
+	Uploaded    bool     `json:"uploaded"`
+	CreatedAt   string   `json:"createdAt"`
+	UsedBy      []string `json:"usedBy"`
+}
+
+type ListImagesResponse struct {
+	Images []CatalogEntryResponse `json:"images"`
+}
+
+type ListKernelsResponse struct {
+	Kernels []CatalogEntryResponse `json:"kernels"`
+}
+
+type CPUTemplateResponse struct {
+	Name   string   `json:"name"`
+	Arch   string   `json:"arch"`
+	Path   string   `json:"path"`
+	UsedBy []string `json:"usedBy"`
+}
+
+type ListCPUTemplatesResponse struct {
+	Static []string              `json:"static"`
+	Custom []CPUTemplateResponse `json:"custom"`
+}
+
+type CPUTemplateVerifyResponse struct {
+	Verified bool   `json:"verified"`
+	Output   string `json:"output"`
+}
+
+type SeccompFilterResponse struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	BPFPath string   `json:"bpfPath"`
+	UsedBy  []string `json:"usedBy"`
+}
+
+type ListSeccompFiltersResponse struct {
+	Builtin []string                `json:"builtin"`
+	Custom  []SeccompFilterResponse `json:"custom"`
+	Default string                  `json:"default"`
+}
+
+type ListInitrdsResponse struct {
+	Initrds []CatalogEntryResponse `json:"initrds"`
+}
@@ -6,6 +6,9 @@ set -euo pipefail
 # sudo su
 # sudo setfacl -m u:${USER}:rw /dev/kvm
 
+# the NoCloud seed drives of cloud-init need mkfs.vfat and mcopy
+# sudo apt-get -y install dosfstools mtools
+
 if ! [ -r /dev/kvm ] && ! [ -w /dev/kvm ]; then
     echo "cannot activate KVM for the user ${USER}"
     exit 1
@@ -33,10 +36,14 @@ echo "Firecracker Jailer"
 if [ "$ARCH" = "aarch64" ]; then
     sudo ln -sfn $PWD/firecracker/release-v1.6.0-aarch64/firecracker-v1.6.0-aarch64 "/usr/bin/firecracker"
     sudo ln -sfn $PWD/firecracker/release-v1.6.0-aarch64/jailer-v1.6.0-aarch64 "/usr/bin/jailer"
+    sudo ln -sfn $PWD/firecracker/release-v1.6.0-aarch64/cpu-template-helper-v1.6.0-aarch64 "/usr/bin/cpu-template-helper"
+    sudo ln -sfn $PWD/firecracker/release-v1.6.0-aarch64/seccompiler-bin-v1.6.0-aarch64 "/usr/bin/seccompiler-bin"
     
 else
     sudo ln -sfn $PWD/firecracker/release-v1.6.0-x86_64/firecracker-v1.6.0-x86_64 "/usr/bin/firecracker"
     sudo ln -sfn $PWD/firecracker/release-v1.6.0-x86_64/jailer-v1.6.0-x86_64 "/usr/bin/jailer"
+    sudo ln -sfn $PWD/firecracker/release-v1.6.0-x86_64/cpu-template-helper-v1.6.0-x86_64 "/usr/bin/cpu-template-helper"
+    sudo ln -sfn $PWD/firecracker/release-v1.6.0-x86_64/seccompiler-bin-v1.6.0-x86_64 "/usr/bin/seccompiler-bin"
 fi
 
 
@@ -14,10 +14,9 @@ import (
 )
 
 var (
-	logConfig     = configs.NewLogginConfig()
-	rootLogger    = logConfig.NewLogger(configs.LoggerOpts{Name: "http-handler"})
-	machineConfig = configs.NewMachineConfig()
-	killCfg       = configs.NewKillConfig()
+	logConfig  = configs.NewLogginConfig()
+	rootLogger = logConfig.NewLogger(configs.LoggerOpts{Name: "http-handler"})
+	killCfg    = configs.NewKillConfig()
 )
 
 func main() {
@@ -30,6 +29,23 @@ func main() {
 	})
 	http.HandleFunc("/create", createRequestHandler)
 	http.HandleFunc("/stop", stopRequestHandler)
+	http.HandleFunc("/v1/vms/", vmsRequestHandler)
+	http.HandleFunc("/v1/networks", networksRequestHandler)
+	http.HandleFunc("/v1/networks/", networksRequestHandler)
+	http.HandleFunc("/v1/disks", disksRequestHandler)
+	http.HandleFunc("/v1/disks/", disksRequestHandler)
+	http.HandleFunc("/v1/volumes", volumesRequestHandler)
+	http.HandleFunc("/v1/volumes/", volumesRequestHandler)
+	http.HandleFunc("/v1/images", catalogRequestHandler(configs.CatalogKindImage))
+	http.HandleFunc("/v1/images/", catalogRequestHandler(configs.CatalogKindImage))
+	http.HandleFunc("/v1/kernels", catalogRequestHandler(configs.CatalogKindKernel))
+	http.HandleFunc("/v1/kernels/", catalogRequestHandler(configs.CatalogKindKernel))
+	http.HandleFunc("/v1/initrds", catalogRequestHandler(configs.CatalogKindInitrd))
+	http.HandleFunc("/v1/initrds/", catalogRequestHandler(configs.CatalogKindInitrd))
+	http.HandleFunc("/v1/cpu-templates", cpuTemplatesRequestHandler)
+	http.HandleFunc("/v1/cpu-templates/", cpuTemplatesRequestHandler)
+	http.HandleFunc("/v1/seccomp-filters", seccompFiltersRequestHandler)
+	http.HandleFunc("/v1/seccomp-filters/", seccompFiltersRequestHandler)
 
 	port := os.Getenv("PORT")
 
@@ -85,6 +101,23 @@ func createRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.ResolveCatalogReferences(&req); err != nil {
+		response := buildCreateVMError(err.Error())
+		w.WriteHeader(managerErrorStatus(err))
+		w.Write(response)
+		return
+	}
+
+	if err := fcManager.ResolveVolumeReferences(&req); err != nil {
+		response := buildCreateVMError(err.Error())
+		w.WriteHeader(managerErrorStatus(err))
+		w.Write(response)
+		return
+	}
+
+	machineConfig := configs.NewMachineConfig()
 	err = machineConfig.WithCreateVMRequest(&req)
 
 	if err != nil {
@@ -103,13 +136,11 @@ func createRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fcManager := managers.CreateFCManagerInstance()
-
 	fcMachine, err := fcManager.StartVM(machineConfig, jailerCfg)
 
 	if err != nil {
 		response := buildCreateVMError("An error occurs when starting the vm: " + err.Error())
-		w.WriteHeader(500)
+		w.WriteHeader(managerErrorStatus(err))
 		w.Write(response)
 		return
 	}
@@ -120,11 +151,30 @@ func createRequestHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := response.CreateVMResponse{
-		IP:    fcMachine.Cfg.NetworkInterfaces[0].StaticConfiguration.IPConfiguration.IPAddr.IP.String(),
 		PID:   pid,
 		VMMiD: fcMachine.Cfg.VMID,
+		Name:  machineConfig.Name,
 	}
 
+	for i, iface := range machineConfig.FcNetworkInterfaces {
+		resp.NetworkInterfaces = append(resp.NetworkInterfaces, response.NetworkInterfaceResponse{
+			IfaceID:        strconv.Itoa(i + 1),
+			CniNetworkName: iface.CNINetworkName,
+			IfName:         iface.IfName,
+			IP:             iface.Result.IPAddr.IP.String(),
+			Mac:            iface.Result.MacAddress,
+			Gateway:        iface.Result.Gateway.String(),
+			AllowMmds:      iface.AllowMMDS,
+			PortMappings:   portMappingResponses(iface.PortMappings),
+		})
+		if iface.Result.IPv6Addr != nil {
+			resp.NetworkInterfaces[i].IPv6 = iface.Result.IPv6Addr.IP.String()
+			resp.NetworkInterfaces[i].IPv6Gateway = iface.Result.IPv6Gateway.String()
+		}
+	}
+	resp.IP = resp.NetworkInterfaces[0].IP
+	resp.IPv6 = resp.NetworkInterfaces[0].IPv6
+
 	response, err := json.Marshal(&resp)
 	if err != nil {
 		errMsg := "failed to marshal create vm response json: " + err.Error()
@@ -139,6 +189,19 @@ func createRequestHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func portMappingResponses(portMappings []*configs.PortMappingConfig) []response.PortMappingResponse {
+	result := []response.PortMappingResponse{}
+	for _, portMapping := range portMappings {
+		result = append(result, response.PortMappingResponse{
+			HostPort:  portMapping.HostPort,
+			GuestPort: portMapping.GuestPort,
+			Protocol:  portMapping.Protocol,
+			HostIP:    portMapping.HostIP,
+		})
+	}
+	return result
+}
+
 func stopRequestHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
@@ -0,0 +1,80 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"sync"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+)
+
+var (
+	// ErrBalloonNotConfigured is returned when a balloon operation targets a VM created without a balloon.
+	ErrBalloonNotConfigured = errors.New("vm was created without a balloon device")
+)
+
+// balloonLock serializes the balloon updates, so the balloon configuration of the VM matches the last update applied.
+var balloonLock sync.Mutex
+
+// UpdateBalloon changes the target size and/or the statistics polling interval of the balloon of a running VM.
+func (instance *FireCrackerManager) UpdateBalloon(vmID string, update *configs.BalloonUpdateConfig) error {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "balloon"})
+
+	balloonLock.Lock()
+	defer balloonLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	if runningVM.MachineConfig.FcBalloon == nil {
+		return ErrBalloonNotConfigured
+	}
+
+	machine := runningVM.StartedMachine.RunningMachine()
+
+	if update.AmountMib != nil {
+		if *update.AmountMib >= runningVM.MachineConfig.Mem {
+			return fmt.Errorf("balloon amountMib must be lower than memSizeMib (%d)", runningVM.MachineConfig.Mem)
+		}
+		if err := machine.UpdateBalloon(context.Background(), *update.AmountMib); err != nil {
+			errorMsg := fmt.Errorf("failed updating balloon size, reason: %s", err)
+			rootLogger.Error(errorMsg.Error(), "vmm-id", vmID)
+			return errorMsg
+		}
+		runningVM.MachineConfig.FcBalloon.AmountMib = *update.AmountMib
+	}
+
+	if update.StatsPollingIntervalS != nil {
+		if err := machine.UpdateBalloonStats(context.Background(), *update.StatsPollingIntervalS); err != nil {
+			errorMsg := fmt.Errorf("failed updating balloon statistics interval, reason: %s", err)
+			rootLogger.Error(errorMsg.Error(), "vmm-id", vmID)
+			return errorMsg
+		}
+		runningVM.MachineConfig.FcBalloon.StatsPollingIntervalS = *update.StatsPollingIntervalS
+	}
+
+	return nil
+}
+
+// BalloonStats returns the latest balloon statistics of a running VM.
+func (instance *FireCrackerManager) BalloonStats(vmID string) (models.BalloonStats, error) {
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return models.BalloonStats{}, err
+	}
+
+	if runningVM.MachineConfig.FcBalloon == nil {
+		return models.BalloonStats{}, ErrBalloonNotConfigured
+	}
+
+	stats, err := runningVM.StartedMachine.RunningMachine().GetBalloonStats(context.Background())
+	if err != nil {
+		return models.BalloonStats{}, fmt.Errorf("failed fetching balloon statistics, reason: %s", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,270 @@
+package managers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/pkg/catalog"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCatalogEntryNotFound is returned when no kernel or image matches the reference.
+	ErrCatalogEntryNotFound = errors.New("catalog entry not found")
+	// ErrCatalogEntryExists is returned when registering a name and version already in the catalog.
+	ErrCatalogEntryExists = errors.New("catalog entry already exists")
+	// ErrCatalogEntryInUse is returned when deleting a kernel or an image used by running VMs.
+	ErrCatalogEntryInUse = errors.New("catalog entry is used by running vms")
+	// ErrInvalidCatalogEntry is returned when a registration or a reference cannot be used.
+	ErrInvalidCatalogEntry = errors.New("invalid catalog entry")
+
+	catalogConfig = configs.NewCatalogConfig()
+)
+
+// catalogLock serializes the changes to the catalog indexes.
+var catalogLock sync.Mutex
+
+// CatalogEntryInfo is a kernel or an image of the catalog with the VMs using it.
+type CatalogEntryInfo struct {
+	*catalog.Entry
+	UsedBy []string
+}
+
+// ListCatalog returns the entries of the kernel or image catalog.
+func (instance *FireCrackerManager) ListCatalog(kind string) ([]*CatalogEntryInfo, error) {
+	entries, err := catalog.Load(catalogConfig.IndexPath(kind))
+	if err != nil {
+		return nil, err
+	}
+	result := []*CatalogEntryInfo{}
+	for _, entry := range entries {
+		result = append(result, catalogEntryInfo(kind, entry))
+	}
+	return result, nil
+}
+
+// GetCatalogEntry returns the entry of the reference, name:version or a name for its latest registered version.
+func (instance *FireCrackerManager) GetCatalogEntry(kind, ref string) (*CatalogEntryInfo, error) {
+	entries, err := catalog.Load(catalogConfig.IndexPath(kind))
+	if err != nil {
+		return nil, err
+	}
+	matches := catalog.Find(entries, ref)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrCatalogEntryNotFound, kind, ref)
+	}
+	return catalogEntryInfo(kind, matches[0]), nil
+}
+
+// RegisterCatalogEntry adds a kernel or an image to the catalog. The file is the path of the registration,
+// left in place, or the uploaded contents when there is no path, stored in the catalog directory.
+func (instance *FireCrackerManager) RegisterCatalogEntry(entryConfig *configs.CatalogEntryConfig, contents io.Reader) (*CatalogEntryInfo, error) {
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	entry := &catalog.Entry{
+		Name:        entryConfig.Name,
+		Version:     entryConfig.Version,
+		Arch:        entryConfig.Arch,
+		Description: entryConfig.Description,
+		Path:        entryConfig.Path,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := checkCatalogEntryAvailable(entryConfig.Kind, entry.ID()); err != nil {
+		return nil, err
+	}
+	indexPath := catalogConfig.IndexPath(entryConfig.Kind)
+	entries, err := catalog.Load(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "catalog"})
+
+	if entry.Path != "" {
+		if _, err := utils.CheckIfExistsAndIsRegular(entry.Path); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCatalogEntry, err)
+		}
+		rootLogger.Info("registering catalog entry", "catalog", entryConfig.Kind, "id", entry.ID(), "path", entry.Path)
+		if entry.SHA256, entry.SizeBytes, err = catalog.Checksum(entry.Path); err != nil {
+			return nil, err
+		}
+	} else {
+		entry.Path = filepath.Join(catalogConfig.UploadDir(entryConfig.Kind), uploadFileName(entry.ID()))
+		entry.Uploaded = true
+		rootLogger.Info("storing uploaded catalog entry", "catalog", entryConfig.Kind, "id", entry.ID(), "path", entry.Path)
+		if entry.SHA256, entry.SizeBytes, err = catalog.Store(entry.Path, contents); err != nil {
+			errorMsg := fmt.Errorf("failed storing upload of %s, reason: %s", entry.ID(), err)
+			rootLogger.Error(errorMsg.Error())
+			return nil, errorMsg
+		}
+	}
+
+	if err := catalog.Save(indexPath, append(entries, entry)); err != nil {
+		if entry.Uploaded {
+			os.Remove(entry.Path)
+		}
+		return nil, err
+	}
+
+	return catalogEntryInfo(entryConfig.Kind, entry), nil
+}
+
+// DeleteCatalogEntry removes an entry from the catalog, it is refused while running or starting VMs use it.
+// The uploaded files are removed, the registered paths are left in place.
+func (instance *FireCrackerManager) DeleteCatalogEntry(kind, ref string) error {
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	indexPath := catalogConfig.IndexPath(kind)
+	entries, err := catalog.Load(indexPath)
+	if err != nil {
+		return err
+	}
+
+	matches := catalog.Find(entries, ref)
+	if len(matches) == 0 {
+		return fmt.Errorf("%w: %s %s", ErrCatalogEntryNotFound, kind, ref)
+	}
+	if len(matches) > 1 {
+		return fmt.Errorf("%w: %s has several versions, delete name:version", ErrInvalidCatalogEntry, ref)
+	}
+	entry := matches[0]
+
+	if usedBy := catalogEntryInfo(kind, entry).UsedBy; len(usedBy) > 0 {
+		return fmt.Errorf("%w: %s is used by vms %s", ErrCatalogEntryInUse, entry.ID(), strings.Join(usedBy, ", "))
+	}
+
+	remaining := []*catalog.Entry{}
+	for _, existing := range entries {
+		if existing != entry {
+			remaining = append(remaining, existing)
+		}
+	}
+	if err := catalog.Save(indexPath, remaining); err != nil {
+		return err
+	}
+
+	if entry.Uploaded {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveCatalogReferences replaces the kernel, initrd and image references of a create request with their paths,
+// the references are rewritten to the IDs of the resolved entries.
+func (instance *FireCrackerManager) ResolveCatalogReferences(createVM *requests.CreateVMRequest) error {
+	if createVM.Kernel != "" {
+		if createVM.KernelPath != "" {
+			return fmt.Errorf("%w: kernel and kernelPath cannot both be given", ErrInvalidCatalogEntry)
+		}
+		entry, err := resolveCatalogReference(configs.CatalogKindKernel, createVM.Kernel)
+		if err != nil {
+			return err
+		}
+		createVM.Kernel, createVM.KernelPath = entry.ID(), entry.Path
+	}
+	if createVM.Initrd != "" {
+		if createVM.InitrdPath != "" {
+			return fmt.Errorf("%w: initrd and initrdPath cannot both be given", ErrInvalidCatalogEntry)
+		}
+		entry, err := resolveCatalogReference(configs.CatalogKindInitrd, createVM.Initrd)
+		if err != nil {
+			return err
+		}
+		createVM.Initrd, createVM.InitrdPath = entry.ID(), entry.Path
+	}
+	if createVM.Image != "" {
+		if createVM.RootDrivePath != "" {
+			return fmt.Errorf("%w: image and rootDrivePath cannot both be given", ErrInvalidCatalogEntry)
+		}
+		entry, err := resolveCatalogReference(configs.CatalogKindImage, createVM.Image)
+		if err != nil {
+			return err
+		}
+		createVM.Image, createVM.RootDrivePath = entry.ID(), entry.Path
+	}
+	return nil
+}
+
+func resolveCatalogReference(kind, ref string) (*catalog.Entry, error) {
+	entries, err := catalog.Load(catalogConfig.IndexPath(kind))
+	if err != nil {
+		return nil, err
+	}
+	matches := catalog.Find(entries, ref)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrCatalogEntryNotFound, kind, ref)
+	}
+	if matches[0].Arch != configs.HostArch() {
+		return nil, fmt.Errorf("%w: %s is built for %s, the host is %s", ErrInvalidCatalogEntry, matches[0].ID(), matches[0].Arch, configs.HostArch())
+	}
+	return matches[0], nil
+}
+
+func catalogEntryInfo(kind string, entry *catalog.Entry) *CatalogEntryInfo {
+	info := &CatalogEntryInfo{
+		Entry:  entry,
+		UsedBy: []string{},
+	}
+	for _, vm := range registry.List() {
+		if usesCatalogEntry(vm.MachineConfig, kind, entry) {
+			info.UsedBy = append(info.UsedBy, vm.ID())
+		}
+	}
+	info.UsedBy = append(info.UsedBy, startingVMs.Find(func(machineConfig *configs.MachineConfig) bool {
+		return usesCatalogEntry(machineConfig, kind, entry)
+	})...)
+	return info
+}
+
+func usesCatalogEntry(machineConfig *configs.MachineConfig, kind string, entry *catalog.Entry) bool {
+	ref, path := machineConfig.Image, machineConfig.RootDrivePath()
+	switch kind {
+	case configs.CatalogKindKernel:
+		ref, path = machineConfig.Kernel, machineConfig.KernelPath
+	case configs.CatalogKindInitrd:
+		ref, path = machineConfig.Initrd, machineConfig.InitrdPath
+	}
+	return ref == entry.ID() || path != "" && sameFile(path, entry.Path)
+}
+
+// checkCatalogFilesExist returns an error if an entry the machine was resolved to was deleted since. The machine
+// must be tracked by startingVMs already, so DeleteCatalogEntry refuses its entries once this returns.
+func checkCatalogFilesExist(machineConfig *configs.MachineConfig) error {
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	refs := []struct{ kind, ref, path string }{
+		{configs.CatalogKindKernel, machineConfig.Kernel, machineConfig.KernelPath},
+		{configs.CatalogKindInitrd, machineConfig.Initrd, machineConfig.InitrdPath},
+		{configs.CatalogKindImage, machineConfig.Image, machineConfig.RootDrivePath()},
+	}
+	for _, ref := range refs {
+		if ref.ref == "" {
+			continue
+		}
+		if _, err := utils.CheckIfExistsAndIsRegular(ref.path); err != nil {
+			return fmt.Errorf("%w: %s %s was deleted", ErrCatalogEntryNotFound, ref.kind, ref.ref)
+		}
+	}
+	return nil
+}
+
+// uploadFileName returns the file name of an uploaded entry, the hash of its ID: names and versions may contain
+// characters a file name cannot, and no mapping of them keeps two IDs apart. The path is kept in the index.
+func uploadFileName(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,45 @@
+package managers
+
+import (
+	"fmt"
+	"open-fire/configs"
+	"open-fire/pkg/cloudinit"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// createSeedDrive creates the NoCloud seed drive of the VM in its jail directory, removed with it when the VM stops,
+// and attaches it read-only. The path is returned so a failed start can remove it.
+func createSeedDrive(rootLogger hclog.Logger, machineConfig *configs.MachineConfig, jailingFcConfig *configs.JailingFirecrackerConfig) (string, error) {
+	hostname := jailingFcConfig.VMMID()
+	if machineConfig.Name != "" {
+		hostname = machineConfig.Name
+	}
+
+	metaData, err := machineConfig.FcCloudInit.NoCloudMetaData(jailingFcConfig.VMMID(), hostname)
+	if err != nil {
+		return "", fmt.Errorf("failed rendering NoCloud meta-data: %w", err)
+	}
+
+	if err := os.MkdirAll(jailingFcConfig.JailerChrootDirectory(), 0755); err != nil {
+		return "", fmt.Errorf("failed creating jail directory: %w", err)
+	}
+
+	// the drive is hard linked in the jail under its base name, which must not collide with the other drives
+	seedPath := filepath.Join(jailingFcConfig.JailerChrootDirectory(), jailingFcConfig.VMMID()+"-cidata.img")
+
+	rootLogger.Info("creating NoCloud seed drive", "path", seedPath)
+
+	if err := cloudinit.CreateNoCloudSeed(seedPath, []byte(machineConfig.FcCloudInit.UserData), metaData); err != nil {
+		return "", err
+	}
+
+	machineConfig.FcAdditionalDrives = append(machineConfig.FcAdditionalDrives, &configs.DriveConfig{
+		Path:     seedPath,
+		ReadOnly: true,
+		DriveID:  configs.SeedDriveID,
+	})
+	return seedPath, nil
+}
@@ -0,0 +1,302 @@
+package managers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrCPUTemplateNotFound is returned when the templates directory has no custom CPU template with the name.
+	ErrCPUTemplateNotFound = errors.New("cpu template not found")
+	// ErrCPUTemplateInUse is returned when deleting a custom CPU template used by running or starting VMs.
+	ErrCPUTemplateInUse = errors.New("cpu template is used by running vms")
+	// ErrInvalidCPUTemplate is returned when a custom CPU template is not a template of the host architecture.
+	ErrInvalidCPUTemplate = errors.New("invalid cpu template")
+
+	cpuTemplatesConfig = configs.NewCPUTemplatesConfig()
+)
+
+// cpuTemplatesLock serializes the changes to the custom CPU templates.
+var cpuTemplatesLock sync.Mutex
+
+// CPUTemplateInfo is a custom CPU template with the VMs using it.
+type CPUTemplateInfo struct {
+	Name   string
+	Arch   string
+	Path   string
+	UsedBy []string
+}
+
+// cpuTemplateFile holds the architecture specific modifiers of a custom CPU template.
+type cpuTemplateFile struct {
+	CpuidModifiers json.RawMessage `json:"cpuid_modifiers"`
+	MsrModifiers   json.RawMessage `json:"msr_modifiers"`
+	RegModifiers   json.RawMessage `json:"reg_modifiers"`
+}
+
+// ListCPUTemplates returns the custom CPU templates, ordered by name.
+func (instance *FireCrackerManager) ListCPUTemplates() ([]*CPUTemplateInfo, error) {
+	entries, err := os.ReadDir(cpuTemplatesConfig.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*CPUTemplateInfo{}, nil
+		}
+		return nil, err
+	}
+	result := []*CPUTemplateInfo{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !entry.Type().IsRegular() || name == entry.Name() {
+			continue
+		}
+		template, err := cpuTemplateInfo(name)
+		if err != nil {
+			continue
+		}
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// GetCPUTemplate returns the custom CPU template with the given name and its contents.
+func (instance *FireCrackerManager) GetCPUTemplate(name string) (*CPUTemplateInfo, []byte, error) {
+	template, err := cpuTemplateInfo(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := os.ReadFile(template.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, contents, nil
+}
+
+// PutCPUTemplate creates or replaces a custom CPU template, it must be a template of the host architecture.
+// The running VMs keep the template they started with. It returns true if the template was created.
+func (instance *FireCrackerManager) PutCPUTemplate(name string, contents []byte) (*CPUTemplateInfo, bool, error) {
+	cpuTemplatesLock.Lock()
+	defer cpuTemplatesLock.Unlock()
+
+	path, err := cpuTemplatesConfig.TemplatePath(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidCPUTemplate, err)
+	}
+	if err := validateCPUTemplate(contents); err != nil {
+		return nil, false, err
+	}
+
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	if err := writeCPUTemplate(path, contents); err != nil {
+		return nil, false, err
+	}
+
+	template, err := cpuTemplateInfo(name)
+	return template, created, err
+}
+
+// DeleteCPUTemplate removes a custom CPU template, it is refused while running or starting VMs use it.
+func (instance *FireCrackerManager) DeleteCPUTemplate(name string) error {
+	cpuTemplatesLock.Lock()
+	defer cpuTemplatesLock.Unlock()
+
+	template, err := cpuTemplateInfo(name)
+	if err != nil {
+		return err
+	}
+	if len(template.UsedBy) > 0 {
+		return fmt.Errorf("%w: %s is used by vms %s", ErrCPUTemplateInUse, name, strings.Join(template.UsedBy, ", "))
+	}
+	return os.Remove(template.Path)
+}
+
+// GenerateCPUTemplate dumps the guest CPU configuration of the host with cpu-template-helper and stores it
+// as a custom CPU template, to be trimmed down to the features to normalize across the fleet.
+func (instance *FireCrackerManager) GenerateCPUTemplate(name string, helperConfig *configs.CPUTemplateHelperConfig) (*CPUTemplateInfo, string, error) {
+	path, err := cpuTemplatesConfig.TemplatePath(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrInvalidCPUTemplate, err)
+	}
+
+	workDir, err := os.MkdirTemp("", "cpu-template-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	configPath, err := writeHelperConfig(workDir, helperConfig, "")
+	if err != nil {
+		return nil, "", err
+	}
+	dumpPath := filepath.Join(workDir, "cpu_config.json")
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "cpu-templates"})
+	rootLogger.Info("dumping guest CPU configuration", "template", name, "kernel", helperConfig.KernelPath)
+
+	output, err := utils.RunCommandCombinedOutput(cpuTemplatesConfig.Helper, "template", "dump", "--config", configPath, "--output", dumpPath)
+	if err != nil {
+		errorMsg := fmt.Errorf("cpu-template-helper template dump failed, reason: %s", err)
+		rootLogger.Error(errorMsg.Error(), "output", output)
+		return nil, output, errorMsg
+	}
+
+	contents, err := os.ReadFile(dumpPath)
+	if err != nil {
+		return nil, output, err
+	}
+
+	cpuTemplatesLock.Lock()
+	defer cpuTemplatesLock.Unlock()
+
+	if err := writeCPUTemplate(path, contents); err != nil {
+		return nil, output, err
+	}
+
+	template, err := cpuTemplateInfo(name)
+	return template, output, err
+}
+
+// VerifyCPUTemplate boots a VM with the custom CPU template through cpu-template-helper and returns
+// whether the guest CPU configuration matches the template, with the helper output.
+func (instance *FireCrackerManager) VerifyCPUTemplate(name string, helperConfig *configs.CPUTemplateHelperConfig) (bool, string, error) {
+	template, err := cpuTemplateInfo(name)
+	if err != nil {
+		return false, "", err
+	}
+
+	workDir, err := os.MkdirTemp("", "cpu-template-")
+	if err != nil {
+		return false, "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	configPath, err := writeHelperConfig(workDir, helperConfig, template.Path)
+	if err != nil {
+		return false, "", err
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "cpu-templates"})
+	rootLogger.Info("verifying CPU template", "template", name, "kernel", helperConfig.KernelPath)
+
+	output, err := utils.RunCommandCombinedOutput(cpuTemplatesConfig.Helper, "template", "verify", "--config", configPath)
+	if err != nil {
+		// the helper exits with an error when the template is not applied as intended
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, output, nil
+		}
+		return false, output, err
+	}
+	return true, output, nil
+}
+
+// resolveCPUTemplate sets the path of the custom CPU template of the machine, if it has one.
+func resolveCPUTemplate(machineConfig *configs.MachineConfig) error {
+	if machineConfig.FcCustomCPUTemplate == "" {
+		return nil
+	}
+	template, err := cpuTemplateInfo(machineConfig.FcCustomCPUTemplate)
+	if err != nil {
+		return err
+	}
+	machineConfig.FcCPUConfigPath = template.Path
+	return nil
+}
+
+// validateCPUTemplate checks the template is a JSON object with the modifiers of the host architecture,
+// Firecracker validates the modifiers themselves.
+func validateCPUTemplate(contents []byte) error {
+	arch, err := cpuTemplateArch(contents)
+	if err != nil {
+		return err
+	}
+	if arch != "" && arch != configs.HostArch() {
+		return fmt.Errorf("%w: the template is for %s, the host is %s", ErrInvalidCPUTemplate, arch, configs.HostArch())
+	}
+	return nil
+}
+
+// cpuTemplateArch returns the architecture of a template from its modifiers, empty if it has none.
+func cpuTemplateArch(contents []byte) (string, error) {
+	templateFile := &cpuTemplateFile{}
+	if err := json.NewDecoder(bytes.NewReader(contents)).Decode(templateFile); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCPUTemplate, err)
+	}
+	x86 := len(templateFile.CpuidModifiers) > 0 || len(templateFile.MsrModifiers) > 0
+	arm := len(templateFile.RegModifiers) > 0
+	switch {
+	case x86 && arm:
+		return "", fmt.Errorf("%w: the template has both x86_64 and aarch64 modifiers", ErrInvalidCPUTemplate)
+	case x86:
+		return "x86_64", nil
+	case arm:
+		return "aarch64", nil
+	default:
+		return "", nil
+	}
+}
+
+func writeCPUTemplate(path string, contents []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func writeHelperConfig(workDir string, helperConfig *configs.CPUTemplateHelperConfig, cpuConfigPath string) (string, error) {
+	config, err := json.MarshalIndent(helperConfig.FirecrackerConfig(cpuConfigPath), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	configPath := filepath.Join(workDir, "vm_config.json")
+	return configPath, os.WriteFile(configPath, config, 0644)
+}
+
+func cpuTemplateInfo(name string) (*CPUTemplateInfo, error) {
+	path, err := cpuTemplatesConfig.TemplatePath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCPUTemplateNotFound, err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCPUTemplateNotFound, name)
+	}
+	arch, err := cpuTemplateArch(contents)
+	if err != nil {
+		return nil, err
+	}
+	template := &CPUTemplateInfo{
+		Name:   name,
+		Arch:   arch,
+		Path:   path,
+		UsedBy: []string{},
+	}
+	for _, vm := range registry.List() {
+		if vm.MachineConfig.FcCustomCPUTemplate == name {
+			template.UsedBy = append(template.UsedBy, vm.ID())
+		}
+	}
+	// a starting VM resolves its template once tracked, it must not be deleted before Firecracker reads it
+	template.UsedBy = append(template.UsedBy, startingVMs.Find(func(machineConfig *configs.MachineConfig) bool {
+		return machineConfig.FcCustomCPUTemplate == name
+	})...)
+	return template, nil
+}
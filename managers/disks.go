@@ -0,0 +1,289 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"open-fire/configs"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrDiskNotFound is returned when the disks directory has no disk with the name.
+	ErrDiskNotFound = errors.New("disk not found")
+	// ErrDiskInUse is returned when mounting a disk attached to a running VM, or starting a VM with a mounted disk.
+	ErrDiskInUse = errors.New("disk is in use")
+	// ErrDiskNotMounted is returned when browsing or unmounting a disk which is not mounted.
+	ErrDiskNotMounted = errors.New("disk is not mounted")
+	// ErrDiskFileNotFound is returned when a mounted disk has no file at the path.
+	ErrDiskFileNotFound = errors.New("file not found")
+	// ErrInvalidDiskPath is returned when a path goes through a file or a symbolic link of the disk.
+	ErrInvalidDiskPath = errors.New("invalid disk path")
+
+	disksConfig = configs.NewDisksConfig()
+)
+
+// disksLock serializes the mounts and unmounts of the disks.
+var disksLock sync.Mutex
+
+// DiskInfo is a disk image of the disks directory.
+type DiskInfo struct {
+	Name        string
+	Path        string
+	SizeBytes   int64
+	Mounted     bool
+	MountDir    string
+	AttachedVMs []string
+}
+
+// DiskFile is an entry of a directory of a mounted disk.
+type DiskFile struct {
+	Name       string
+	Type       string
+	SizeBytes  int64
+	Mode       fs.FileMode
+	ModTime    int64
+	LinkTarget string
+}
+
+// ListDisks returns the disk images of the disks directory, ordered by name.
+func (instance *FireCrackerManager) ListDisks() ([]*DiskInfo, error) {
+	entries, err := os.ReadDir(disksConfig.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*DiskInfo{}, nil
+		}
+		return nil, err
+	}
+	result := []*DiskInfo{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		disk, err := diskInfo(entry.Name())
+		if err != nil {
+			continue
+		}
+		result = append(result, disk)
+	}
+	return result, nil
+}
+
+// GetDisk returns the disk image with the given name.
+func (instance *FireCrackerManager) GetDisk(name string) (*DiskInfo, error) {
+	return diskInfo(name)
+}
+
+// MountDisk loop mounts the disk read-only in the mount directory, it is refused while a running VM uses the disk.
+// The ext4 journal is not replayed, so a disk left dirty by a crashed guest is not modified.
+func (instance *FireCrackerManager) MountDisk(name string) (*DiskInfo, error) {
+	disksLock.Lock()
+	defer disksLock.Unlock()
+
+	disk, err := diskInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	if disk.Mounted {
+		return disk, nil
+	}
+	if len(disk.AttachedVMs) > 0 {
+		return nil, fmt.Errorf("%w: %s is attached to vms %s", ErrDiskInUse, name, strings.Join(disk.AttachedVMs, ", "))
+	}
+	// a starting VM is tracked before it checks its disks under the lock, so either it sees the mount or this sees the VM
+	if vms := startingVMs.ListByDrive(disk.Path); len(vms) > 0 {
+		return nil, fmt.Errorf("%w: %s is attached to starting vms %s", ErrDiskInUse, name, strings.Join(vms, ", "))
+	}
+
+	mountDir := disksConfig.DiskMountDir(name)
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "disks"})
+	rootLogger.Info("mounting disk", "disk", name, "mount-dir", mountDir)
+
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := utils.Mount(disk.Path, mountDir, "loop,ro,noload"); err != nil {
+		// noload is only known to ext3 and ext4
+		if err := utils.Mount(disk.Path, mountDir, "loop,ro"); err != nil {
+			os.Remove(mountDir)
+			errorMsg := fmt.Errorf("failed mounting disk %s, reason: %s", name, err)
+			rootLogger.Error(errorMsg.Error())
+			return nil, errorMsg
+		}
+	}
+
+	return diskInfo(name)
+}
+
+// UnmountDisk unmounts the disk and removes its mount directory.
+func (instance *FireCrackerManager) UnmountDisk(name string) error {
+	disksLock.Lock()
+	defer disksLock.Unlock()
+
+	disk, err := diskInfo(name)
+	if err != nil {
+		return err
+	}
+	if !disk.Mounted {
+		return fmt.Errorf("%w: %s", ErrDiskNotMounted, name)
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "disks"})
+	rootLogger.Info("unmounting disk", "disk", name, "mount-dir", disk.MountDir)
+
+	if err := utils.Umount(disk.MountDir); err != nil {
+		errorMsg := fmt.Errorf("failed unmounting disk %s, reason: %s", name, err)
+		rootLogger.Error(errorMsg.Error())
+		return errorMsg
+	}
+	return os.Remove(disk.MountDir)
+}
+
+// StatDiskFile returns the path on the host and the information of a file of a mounted disk.
+// The path is relative to the root of the disk, symbolic links are not followed.
+func (instance *FireCrackerManager) StatDiskFile(name, path string) (string, fs.FileInfo, error) {
+	disk, err := diskInfo(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if !disk.Mounted {
+		return "", nil, fmt.Errorf("%w: %s", ErrDiskNotMounted, name)
+	}
+	return resolveDiskPath(disk.MountDir, path)
+}
+
+// ListDiskFiles returns the entries of a directory of a mounted disk, ordered by name.
+func (instance *FireCrackerManager) ListDiskFiles(name, path string) ([]*DiskFile, error) {
+	hostPath, info, err := instance.StatDiskFile(name, path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is not a directory", ErrInvalidDiskPath, path)
+	}
+	entries, err := os.ReadDir(hostPath)
+	if err != nil {
+		return nil, err
+	}
+	result := []*DiskFile{}
+	for _, entry := range entries {
+		entryInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, diskFile(filepath.Join(hostPath, entry.Name()), entryInfo))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// checkDisksNotMounted returns an error if one of the drives of the machine is a mounted disk.
+// The machine must be tracked by startingVMs already, so MountDisk refuses its disks once this returns.
+func checkDisksNotMounted(machineConfig *configs.MachineConfig) error {
+	disksLock.Lock()
+	defer disksLock.Unlock()
+
+	for _, drivePath := range machineConfig.DrivePaths() {
+		name := filepath.Base(drivePath)
+		diskPath, err := disksConfig.DiskPath(name)
+		if err != nil || !sameFile(diskPath, drivePath) {
+			continue
+		}
+		if diskMounted(name) {
+			return fmt.Errorf("%w: %s is mounted for inspection", ErrDiskInUse, name)
+		}
+	}
+	return nil
+}
+
+func diskInfo(name string) (*DiskInfo, error) {
+	path, err := disksConfig.DiskPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiskNotFound, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", ErrDiskNotFound, name)
+	}
+	disk := &DiskInfo{
+		Name:        name,
+		Path:        path,
+		SizeBytes:   info.Size(),
+		Mounted:     diskMounted(name),
+		AttachedVMs: []string{},
+	}
+	if disk.Mounted {
+		disk.MountDir = disksConfig.DiskMountDir(name)
+	}
+	for _, vm := range registry.ListByDrive(path) {
+		disk.AttachedVMs = append(disk.AttachedVMs, vm.ID())
+	}
+	return disk, nil
+}
+
+// diskMounted returns true if the disk is mounted, the mount table is the state so it survives restarts.
+func diskMounted(name string) bool {
+	mountDir := disksConfig.DiskMountDir(name)
+	mountPoints, err := utils.MountPointsUnder(mountDir)
+	if err != nil {
+		return false
+	}
+	for _, mountPoint := range mountPoints {
+		if mountPoint == mountDir {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDiskPath resolves a path below the root of a mounted disk, refusing symbolic links
+// on the way as they point in the guest filesystem, not the host one.
+func resolveDiskPath(root, path string) (string, fs.FileInfo, error) {
+	hostPath := root
+	info, err := os.Lstat(hostPath)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, segment := range strings.Split(filepath.Clean("/"+path), "/") {
+		if segment == "" {
+			continue
+		}
+		if info.Mode()&fs.ModeSymlink != 0 || !info.IsDir() {
+			return "", nil, fmt.Errorf("%w: %s is not a directory", ErrInvalidDiskPath, strings.TrimPrefix(hostPath, root))
+		}
+		hostPath = filepath.Join(hostPath, segment)
+		if info, err = os.Lstat(hostPath); err != nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrDiskFileNotFound, filepath.Clean("/"+path))
+		}
+	}
+	return hostPath, info, nil
+}
+
+func diskFile(hostPath string, info fs.FileInfo) *DiskFile {
+	file := &DiskFile{
+		Name:      info.Name(),
+		SizeBytes: info.Size(),
+		Mode:      info.Mode().Perm(),
+		ModTime:   info.ModTime().Unix(),
+	}
+	switch {
+	case info.IsDir():
+		file.Type = "directory"
+	case info.Mode()&fs.ModeSymlink != 0:
+		file.Type = "symlink"
+		file.LinkTarget, _ = os.Readlink(hostPath)
+	case info.Mode().IsRegular():
+		file.Type = "file"
+	default:
+		file.Type = "other"
+	}
+	return file
+}
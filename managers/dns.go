@@ -0,0 +1,55 @@
+package managers
+
+import (
+	"errors"
+	"net"
+	"open-fire/configs"
+	"open-fire/pkg/dns"
+)
+
+var (
+	dnsConfig = configs.NewDNSConfig()
+	dnsServer = dns.NewServer(logConfig.NewLogger(configs.LoggerOpts{Name: "dns"}), dnsConfig, resolveVM)
+)
+
+var (
+	// ErrNameConflict is returned when a running VM already has the requested name.
+	ErrNameConflict = errors.New("vm name already in use")
+)
+
+// resolveVM returns the IPs of the interface, on the network, of the running VM with the name or ID.
+func resolveVM(nameOrID, network string) ([]net.IP, bool) {
+	vm, ok := registry.FindByNameOrID(nameOrID)
+	if !ok {
+		return nil, false
+	}
+	for _, iface := range vm.MachineConfig.FcNetworkInterfaces {
+		if iface.CNINetworkName == network && iface.Result != nil {
+			return iface.Result.IPs(), true
+		}
+	}
+	return nil, false
+}
+
+// useEmbeddedDNS makes the resolver listen on the gateway of the primary interface and pushes it to the guest.
+// On the networks forwarding the other queries, it is the only nameserver, in place of the ones of the CNI result
+// which stay the upstreams. On the others, it refuses the other queries and comes before the CNI nameservers.
+// If the resolver cannot listen, the guest keeps the CNI nameservers.
+func useEmbeddedDNS(interfaces []*configs.NetworkInterfaceConfig) error {
+	if !dnsConfig.Enabled || len(interfaces) == 0 {
+		return nil
+	}
+	primary := interfaces[0].Result
+	forward := dnsConfig.Forwards(interfaces[0].CNINetworkName)
+	if err := dnsServer.Listen(primary.Gateway, forward); err != nil {
+		rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "dns"})
+		rootLogger.Warn("embedded dns resolver unavailable, keeping the CNI nameservers", "reason", err)
+		return nil
+	}
+	if forward {
+		primary.Nameservers = []string{primary.Gateway.String()}
+	} else {
+		primary.Nameservers = append([]string{primary.Gateway.String()}, primary.Nameservers...)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/hashicorp/go-hclog"
+)
+
+var (
+	// ErrInvalidDriveUpdate is returned when a drive cannot take the requested backing file.
+	ErrInvalidDriveUpdate = errors.New("invalid drive update")
+
+	drivesLock sync.Mutex
+)
+
+// UpdateDrivePath replaces the backing file of a non-root drive of a running VM, the guest sees the new
+// contents after a rescan of the block device. The file must be a disk of the disks directory, it is hard linked
+// in the jail of the VM, or bind mounted when it lives on another filesystem.
+func (instance *FireCrackerManager) UpdateDrivePath(vmID, driveID, pathOnHost string) error {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "drives"})
+
+	drivesLock.Lock()
+	defer drivesLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	machine := runningVM.StartedMachine.RunningMachine()
+
+	drive := findDrive(machine.Cfg.Drives, driveID)
+	if drive == nil {
+		return fmt.Errorf("drive %s: %w", driveID, ErrDeviceNotFound)
+	}
+	if firecracker.BoolValue(drive.IsRootDevice) {
+		return fmt.Errorf("%w: the root drive cannot be swapped", ErrInvalidDriveUpdate)
+	}
+
+	// held until the drive config has the new path, so MountDisk either sees the VM attached or is seen here
+	disksLock.Lock()
+	defer disksLock.Unlock()
+
+	pathOnHost, err = swappableDiskPath(pathOnHost)
+	if err != nil {
+		return err
+	}
+	// the volume locks are taken at start for the lifetime of the VM
+	if _, ok := volumesConfig.VolumeName(pathOnHost); ok {
+		return fmt.Errorf("%w: volumes can only be attached at create time", ErrInvalidDriveUpdate)
+	}
+	for _, driveConfig := range runningVM.MachineConfig.FcAdditionalDrives {
+		if _, ok := volumesConfig.VolumeName(driveConfig.Path); ok && driveConfig.DriveID == driveID {
+			return fmt.Errorf("%w: drive %s is backed by a volume", ErrInvalidDriveUpdate, driveID)
+		}
+	}
+
+	jailRoot := filepath.Join(runningVM.JailingFcConfig.JailerChrootDirectory(), "root")
+	fileName := fmt.Sprintf("%s-%s-%s", driveID, strings.ToLower(utils.RandStringWithDigitsBytes(8)), filepath.Base(pathOnHost))
+	jailPath := filepath.Join(jailRoot, fileName)
+
+	if err := linkIntoJail(pathOnHost, jailPath); err != nil {
+		errorMsg := fmt.Errorf("failed linking %s in the jail, reason: %s", pathOnHost, err)
+		rootLogger.Error(errorMsg.Error(), "vmm-id", vmID, "drive-id", driveID)
+		return errorMsg
+	}
+
+	if err := machine.UpdateGuestDrive(context.Background(), driveID, fileName); err != nil {
+		releaseJailFile(rootLogger, jailPath)
+		errorMsg := fmt.Errorf("failed updating drive path, reason: %s", err)
+		rootLogger.Error(errorMsg.Error(), "vmm-id", vmID, "drive-id", driveID)
+		return errorMsg
+	}
+
+	releaseJailFile(rootLogger, filepath.Join(jailRoot, filepath.Base(firecracker.StringValue(drive.PathOnHost))))
+	drive.PathOnHost = firecracker.String(fileName)
+
+	for _, driveConfig := range runningVM.MachineConfig.FcAdditionalDrives {
+		if driveConfig.DriveID == driveID {
+			driveConfig.Path = pathOnHost
+		}
+	}
+
+	rootLogger.Info("drive path updated", "vmm-id", vmID, "drive-id", driveID, "path", pathOnHost)
+
+	return nil
+}
+
+// linkIntoJail makes a host file available in the jail. The link shares the inode of the host file, so its owner
+// and mode are left untouched, as for the drives linked at start.
+func linkIntoJail(source, target string) error {
+	err := os.Link(source, target)
+	if errors.Is(err, syscall.EXDEV) {
+		var file *os.File
+		if file, err = os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); err != nil {
+			return err
+		}
+		file.Close()
+		if err = utils.BindMount(source, target); err != nil {
+			os.Remove(target)
+			return err
+		}
+	}
+	return err
+}
+
+// swappableDiskPath returns the path of the disk a drive can be swapped to: a regular file of the disks directory,
+// not mounted for inspection. Other host files are refused, the guest gets read-write access to them.
+// disksLock must be held.
+func swappableDiskPath(pathOnHost string) (string, error) {
+	name := filepath.Base(pathOnHost)
+	diskPath, err := disksConfig.DiskPath(name)
+	if err != nil || filepath.Clean(pathOnHost) != diskPath {
+		return "", fmt.Errorf("%w: the backing file must be a disk of %s", ErrInvalidDriveUpdate, disksConfig.Dir)
+	}
+	// symbolic links could point anywhere on the host
+	info, err := os.Lstat(diskPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidDriveUpdate, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s is not a regular file", ErrInvalidDriveUpdate, diskPath)
+	}
+
+	if diskMounted(name) {
+		return "", fmt.Errorf("%w: %s is mounted for inspection", ErrDiskInUse, name)
+	}
+	return diskPath, nil
+}
+
+// releaseJailFile removes a file of the jail, unmounting it first if it was bind mounted.
+// The host file it points to is left untouched.
+func releaseJailFile(rootLogger hclog.Logger, path string) {
+	if mountPoints, err := utils.MountPointsUnder(path); err == nil {
+		for _, mountPoint := range mountPoints {
+			if err := utils.Umount(mountPoint); err != nil {
+				rootLogger.Warn("failed unmounting jail file", "path", mountPoint, "reason", err)
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		rootLogger.Warn("failed removing jail file", "path", path, "reason", err)
+	}
+}
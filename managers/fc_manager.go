@@ -3,11 +3,13 @@ package managers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"open-fire/configs"
 	"open-fire/pkg/strategy"
 	"open-fire/pkg/strategy/arbitrary"
 	"open-fire/pkg/vmm"
+	"open-fire/pkg/vmm/cni"
 	"open-fire/pkg/vmm/pid"
 	"open-fire/utils"
 	"os"
@@ -23,6 +25,16 @@ var (
 	logConfig     = configs.NewLogginConfig()
 	tracingConfig = configs.NewTracingConfig("open-fire-vmm-run")
 	cniConfig     = configs.NewCNIConfig()
+	registry      = NewVMRegistry()
+)
+
+var (
+	// ErrIPConflict is returned when a requested static IP is already leased on the network.
+	ErrIPConflict = cni.ErrIPAlreadyLeased
+	// ErrPortConflict is returned when a requested host port is already mapped or in use on the host.
+	ErrPortConflict = errors.New("host port already in use")
+	// ErrInvalidHostIP is returned when the host IP of a port mapping is not assigned to the host.
+	ErrInvalidHostIP = errors.New("invalid host ip")
 )
 
 type FireCrackerManager struct {
@@ -51,6 +63,86 @@ func (instance *FireCrackerManager) StartVM(machineConfig *configs.MachineConfig
 		}
 	}
 
+	// the name, static IPs and host ports of a starting VM are reserved until it is registered or fails to start,
+	// and its networks cannot be deleted, DeleteNetwork holds the lock while checking
+	networksLock.Lock()
+	err := startingVMs.Reserve(jailingFcConfig.VMMID(), machineConfig)
+	networksLock.Unlock()
+	if err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+	defer startingVMs.Remove(jailingFcConfig.VMMID())
+
+	if err := checkDisksNotMounted(machineConfig); err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+
+	if err := checkCatalogFilesExist(machineConfig); err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+
+	if err := resolveCPUTemplate(machineConfig); err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+
+	if err := resolveSeccompFilter(machineConfig); err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+
+	volumeLocks, err := lockVolumes(machineConfig)
+	if err != nil {
+		rootLogger.Error(err.Error())
+		return nil, err
+	}
+
+	// files created in the jail directory before the start, removed if it fails
+	jailFiles := []string{}
+	removeJailFiles := func() {
+		for _, path := range jailFiles {
+			os.Remove(path)
+		}
+	}
+
+	if machineConfig.HasRootDrive() && !machineConfig.RootDriveReadOnly() {
+		clonePath, err := cloneRootfs(rootLogger, machineConfig, jailingFcConfig)
+		if err != nil {
+			unlockVolumes(volumeLocks)
+			errorMsg := fmt.Errorf("failed cloning root filesystem, reason: %w", err)
+			rootLogger.Error(errorMsg.Error())
+			return nil, errorMsg
+		}
+		jailFiles = append(jailFiles, clonePath)
+	}
+
+	if machineConfig.FcCloudInit.OnSeedDrive() {
+		seedPath, err := createSeedDrive(rootLogger, machineConfig, jailingFcConfig)
+		if err != nil {
+			removeJailFiles()
+			unlockVolumes(volumeLocks)
+			errorMsg := fmt.Errorf("failed creating cloud-init seed drive, reason: %w", err)
+			rootLogger.Error(errorMsg.Error())
+			return nil, errorMsg
+		}
+		jailFiles = append(jailFiles, seedPath)
+	}
+
+	if machineConfig.FcSeccompFilterPath != "" {
+		filterPath, err := placeSeccompFilter(rootLogger, machineConfig, jailingFcConfig)
+		if err != nil {
+			removeJailFiles()
+			unlockVolumes(volumeLocks)
+			errorMsg := fmt.Errorf("failed copying seccomp filter in the jail, reason: %w", err)
+			rootLogger.Error(errorMsg.Error())
+			return nil, errorMsg
+		}
+		jailFiles = append(jailFiles, filterPath)
+	}
+
 	rootLogger.Trace("configuring tracing", "enabled", tracingConfig.Enable, "application-name", tracingConfig.ApplicationName)
 
 	vmmStrategy := configs.DefaultFirectackerStrategy(machineConfig).
@@ -58,11 +150,27 @@ func (instance *FireCrackerManager) StartVM(machineConfig *configs.MachineConfig
 			// add this one after the previous one so by he logic,
 			// this one will be placed and executed before the first one
 			return arbitrary.NewHandlerPlacement(strategy.
-				NewMetadataExtractorHandler(rootLogger, machineConfig.FcMetadata), firecracker.CreateBootSourceHandlerName)
+				NewMetadataExtractorHandler(rootLogger, jailingFcConfig.VMMID(), machineConfig), firecracker.CreateBootSourceHandlerName)
+		})
+
+	if machineConfig.FcCPUConfigPath != "" {
+		vmmStrategy = vmmStrategy.AddRequirements(func() *arbitrary.HandlerPlacement {
+			return arbitrary.NewHandlerPlacement(strategy.
+				NewCPUConfigHandler(rootLogger, machineConfig.FcCPUConfigPath), firecracker.CreateBootSourceHandlerName)
+		})
+	}
+
+	if machineConfig.FcBalloon != nil {
+		vmmStrategy = vmmStrategy.AddRequirements(func() *arbitrary.HandlerPlacement {
+			return arbitrary.NewHandlerPlacement(firecracker.NewCreateBalloonHandler(machineConfig.FcBalloon.AmountMib,
+				machineConfig.FcBalloon.DeflateOnOom,
+				machineConfig.FcBalloon.StatsPollingIntervalS), firecracker.AttachDrivesHandlerName)
 		})
+	}
 
 	vmmProvider := vmm.NewDefaultProvider(cniConfig, jailingFcConfig, machineConfig).
-		WithHandlersAdapter(vmmStrategy)
+		WithHandlersAdapter(vmmStrategy).
+		WithNetworkReadyHook(useEmbeddedDNS)
 
 	vmmCtx, vmmCancel := context.WithCancel(context.Background())
 
@@ -74,11 +182,28 @@ func (instance *FireCrackerManager) StartVM(machineConfig *configs.MachineConfig
 
 	startedMachine, runErr := vmmProvider.Start(vmmCtx)
 	if runErr != nil {
-		errorMsg := fmt.Errorf("firecracker VMM did not start, run failed, reason: %s", runErr)
+		errorMsg := fmt.Errorf("firecracker VMM did not start, run failed, reason: %w", runErr)
 		rootLogger.Error(errorMsg.Error())
+		removeJailFiles()
+		unlockVolumes(volumeLocks)
 		return nil, errorMsg
 	}
 
+	registry.Add(&RunningVM{
+		StartedMachine:  startedMachine,
+		MachineConfig:   machineConfig,
+		JailingFcConfig: jailingFcConfig,
+	})
+
+	go func() {
+		// release the CNI networks and forget the VM once the VMM exits,
+		// whichever way it was stopped
+		startedMachine.Wait(context.Background())
+		startedMachine.Cleanup(make(chan bool, 1))
+		registry.Remove(jailingFcConfig.VMMID())
+		unlockVolumes(volumeLocks)
+	}()
+
 	return startedMachine.RunningMachine(), nil
 
 }
@@ -113,11 +238,15 @@ func (instance *FireCrackerManager) StopVM(killCfg *configs.KillConfig, jailingF
 
 	rootLogger.Info(jailingFcConfig.JailerChrootDirectory())
 
+	// the VM is forgotten as soon as the VMM exits, keep its configuration for the cleanup
+	runningVM, _ := registry.Get(jailingFcConfig.VMMID())
+
 	socketPath, hasSocket, existsErr := jailingFcConfig.SocketPathIfExists()
 
 	if existsErr != nil {
 		errorMsg := fmt.Errorf("failed checking if the VMM socket file exists, reason: %s", existsErr)
 		rootLogger.Error(errorMsg.Error())
+		retainVMRootfs(rootLogger, runningVM)
 		removeJailerChrootDirectory(rootLogger, *jailingFcConfig)
 		return "", errorMsg
 	}
@@ -146,9 +275,16 @@ func (instance *FireCrackerManager) StopVM(killCfg *configs.KillConfig, jailingF
 
 	}
 
+	retainedRootfs := retainVMRootfs(rootLogger, runningVM)
+
 	removeJailerChrootDirectory(rootLogger, *jailingFcConfig)
 
+	registry.Remove(jailingFcConfig.VMMID())
+
 	result := fmt.Sprintf("VM with id: %s has been stopped", jailingFcConfig.VMMID())
+	if retainedRootfs != "" {
+		result += fmt.Sprintf(", root filesystem kept at %s", retainedRootfs)
+	}
 	if resultAarch64 != "" {
 		result += " " + resultAarch64
 	}
@@ -158,6 +294,14 @@ func (instance *FireCrackerManager) StopVM(killCfg *configs.KillConfig, jailingF
 
 func removeJailerChrootDirectory(rootLogger hclog.Logger, jailingFcConfig configs.JailingFirecrackerConfig) {
 	rootLogger.Info("cleaning up jail directory")
+	// drives swapped in from other filesystems are bind mounted in the jail
+	if mountPoints, err := utils.MountPointsUnder(jailingFcConfig.JailerChrootDirectory()); err == nil {
+		for _, mountPoint := range mountPoints {
+			if err := utils.Umount(mountPoint); err != nil {
+				rootLogger.Error("jail mount removal status", "path", mountPoint, "error", err)
+			}
+		}
+	}
 	if err := os.RemoveAll(jailingFcConfig.JailerChrootDirectory()); err != nil {
 		rootLogger.Error("jail directory removal status", "error", err)
 	}
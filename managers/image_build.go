@@ -0,0 +1,156 @@
+package managers
+
+import (
+	"fmt"
+	"open-fire/configs"
+	"open-fire/pkg/catalog"
+	"open-fire/pkg/rootfs"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// imageBuildHeadroomMib is the free space left in the built images on top of a quarter of their contents.
+const imageBuildHeadroomMib = 64
+
+// BuildImage builds an ext4 root filesystem from an image of a docker save archive and registers it in the image catalog.
+// The layers are flattened, the init running the command of the image and the guest agent are injected,
+// then the tree is copied in a new ext4 image. The build happens in the catalog directory.
+func (instance *FireCrackerManager) BuildImage(buildConfig *configs.ImageBuildConfig) (*CatalogEntryInfo, error) {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "image-build"})
+
+	entryConfig := buildConfig.EntryConfig("")
+	entryID := (&catalog.Entry{Name: entryConfig.Name, Version: entryConfig.Version}).ID()
+
+	if err := checkCatalogEntryAvailable(configs.CatalogKindImage, entryID); err != nil {
+		return nil, err
+	}
+	if _, err := utils.CheckIfExistsAndIsRegular(buildConfig.ArchivePath); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCatalogEntry, err)
+	}
+
+	uploadDir := catalogConfig.UploadDir(configs.CatalogKindImage)
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, err
+	}
+	workDir, err := os.MkdirTemp(uploadDir, ".build-")
+	if err != nil {
+		return nil, err
+	}
+	mounted := false
+	defer func() {
+		// never remove the work directory with the image still mounted in it
+		if !mounted {
+			os.RemoveAll(workDir)
+		}
+	}()
+
+	treeDir := filepath.Join(workDir, "rootfs")
+	mountDir := filepath.Join(workDir, "mnt")
+	imagePath := filepath.Join(workDir, "rootfs.ext4")
+
+	rootLogger.Info("flattening image layers", "archive", buildConfig.ArchivePath, "tag", buildConfig.Tag)
+
+	imageConfig, err := rootfs.ExtractImage(buildConfig.ArchivePath, buildConfig.Tag, treeDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCatalogEntry, err)
+	}
+	if err := rootfs.InstallInit(treeDir, imageConfig, catalogConfig.GuestAgent); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCatalogEntry, err)
+	}
+
+	usedBytes, err := rootfs.UsedBytes(treeDir)
+	if err != nil {
+		return nil, err
+	}
+	neededMib := int(usedBytes*5/4/(1<<20)) + imageBuildHeadroomMib
+	sizeMib := buildConfig.SizeMib
+	if sizeMib == 0 {
+		sizeMib = neededMib
+	} else if sizeMib < neededMib {
+		return nil, fmt.Errorf("%w: sizeMib must be at least %d for the image contents", ErrInvalidCatalogEntry, neededMib)
+	}
+
+	rootLogger.Info("creating ext4 image", "size-mib", sizeMib, "command", strings.Join(imageConfig.Command(), " "))
+
+	if err := utils.CreateRootFSFile(imagePath, sizeMib); err != nil {
+		return nil, fmt.Errorf("failed creating image file, reason: %s", err)
+	}
+	if err := utils.MkfsExt4(imagePath); err != nil {
+		return nil, fmt.Errorf("failed creating ext4 filesystem, reason: %s", err)
+	}
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := utils.Mount(imagePath, mountDir, "loop"); err != nil {
+		return nil, fmt.Errorf("failed mounting image, reason: %s", err)
+	}
+	mounted = true
+	copyErr := utils.CopyDirContents(treeDir, mountDir)
+	if err := utils.Umount(mountDir); err != nil {
+		errorMsg := fmt.Errorf("failed unmounting image, %s is left in place, reason: %s", workDir, err)
+		rootLogger.Error(errorMsg.Error())
+		return nil, errorMsg
+	}
+	mounted = false
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed copying image contents, reason: %s", copyErr)
+	}
+
+	entry := &catalog.Entry{
+		Name:        entryConfig.Name,
+		Version:     entryConfig.Version,
+		Arch:        imageConfig.Arch(),
+		Description: entryConfig.Description,
+		Path:        filepath.Join(uploadDir, strings.ReplaceAll(entryID, ":", "-")),
+		Uploaded:    true,
+	}
+	if entry.Arch == "" {
+		entry.Arch = configs.HostArch()
+	}
+	if entry.SHA256, entry.SizeBytes, err = catalog.Checksum(imagePath); err != nil {
+		return nil, err
+	}
+
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	// the build ran unlocked, the ID may have been registered since
+	if err := checkCatalogEntryAvailable(configs.CatalogKindImage, entryID); err != nil {
+		return nil, err
+	}
+	indexPath := catalogConfig.IndexPath(configs.CatalogKindImage)
+	entries, err := catalog.Load(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(imagePath, entry.Path); err != nil {
+		return nil, err
+	}
+	entry.CreatedAt = time.Now().UTC()
+	if err := catalog.Save(indexPath, append(entries, entry)); err != nil {
+		os.Remove(entry.Path)
+		return nil, err
+	}
+
+	rootLogger.Info("image built", "id", entryID, "path", entry.Path, "sha256", entry.SHA256)
+
+	return catalogEntryInfo(configs.CatalogKindImage, entry), nil
+}
+
+// checkCatalogEntryAvailable returns an error if the catalog has an entry with the ID.
+func checkCatalogEntryAvailable(kind, entryID string) error {
+	entries, err := catalog.Load(catalogConfig.IndexPath(kind))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.ID() == entryID {
+			return fmt.Errorf("%w: %s %s", ErrCatalogEntryExists, kind, entryID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/pkg/strategy"
+	"sync"
+)
+
+var (
+	// ErrInvalidMetadata is returned when the metadata would be invalid once updated.
+	ErrInvalidMetadata = errors.New("invalid metadata")
+)
+
+// metadataLock serializes the metadata updates, which replace the whole MMDS contents.
+var metadataLock sync.Mutex
+
+// GetMetadata returns the MMDS contents of a running VM, the generated keys included.
+func (instance *FireCrackerManager) GetMetadata(vmID string) (interface{}, error) {
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents interface{}
+	if err := runningVM.StartedMachine.RunningMachine().GetMetadata(context.Background(), &contents); err != nil {
+		return nil, fmt.Errorf("failed fetching metadata, reason: %s", err)
+	}
+
+	return contents, nil
+}
+
+// PutMetadata replaces the user metadata of a running VM, the generated keys are kept.
+func (instance *FireCrackerManager) PutMetadata(vmID string, metadata *configs.MetadataConfig) error {
+	metadataLock.Lock()
+	defer metadataLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	return setMetadata(runningVM, metadata)
+}
+
+// PatchMetadata applies a JSON merge patch to the user metadata of a running VM.
+func (instance *FireCrackerManager) PatchMetadata(vmID string, patch *configs.MetadataConfig) error {
+	metadataLock.Lock()
+	defer metadataLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	metadata := runningVM.MachineConfig.FcMetadata.Merge(patch)
+	if err := metadata.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, err)
+	}
+
+	return setMetadata(runningVM, metadata)
+}
+
+func setMetadata(runningVM *RunningVM, metadata *configs.MetadataConfig) error {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "metadata"})
+
+	previous := runningVM.MachineConfig.FcMetadata
+	runningVM.MachineConfig.FcMetadata = metadata
+
+	if err := runningVM.StartedMachine.RunningMachine().SetMetadata(context.Background(), strategy.MMDSContents(runningVM.ID(), runningVM.MachineConfig)); err != nil {
+		runningVM.MachineConfig.FcMetadata = previous
+		errorMsg := fmt.Errorf("failed setting metadata, reason: %s", err)
+		rootLogger.Error(errorMsg.Error(), "vmm-id", runningVM.ID())
+		return errorMsg
+	}
+
+	return nil
+}
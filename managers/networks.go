@@ -0,0 +1,159 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"open-fire/configs"
+	"open-fire/pkg/vmm/cni"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrNetworkNotFound is returned when no conflist defines the network.
+	ErrNetworkNotFound = errors.New("network not found")
+	// ErrNetworkExists is returned when the network name or the bridge is already used.
+	ErrNetworkExists = errors.New("network already exists")
+	// ErrSubnetOverlap is returned when the subnet overlaps the subnet of another network.
+	ErrSubnetOverlap = errors.New("subnet overlaps an existing network")
+	// ErrNetworkInUse is returned when deleting a network with attached VMs.
+	ErrNetworkInUse = errors.New("network has attached vms")
+)
+
+// networksLock serializes the changes to the CNI configuration directory.
+var networksLock sync.Mutex
+
+// NetworkInfo is a CNI network with the VMs attached to it.
+type NetworkInfo struct {
+	*cni.Network
+	AttachedVMs []string
+}
+
+// ListNetworks returns the CNI networks of the configuration directory.
+func (instance *FireCrackerManager) ListNetworks() ([]*NetworkInfo, error) {
+	networks, err := cni.ListNetworks(cniConfig.ConfDir)
+	if err != nil {
+		return nil, err
+	}
+	result := []*NetworkInfo{}
+	for _, network := range networks {
+		result = append(result, networkInfo(network))
+	}
+	return result, nil
+}
+
+// GetNetwork returns the CNI network with the given name.
+func (instance *FireCrackerManager) GetNetwork(name string) (*NetworkInfo, error) {
+	network, err := findNetwork(name)
+	if err != nil {
+		return nil, err
+	}
+	return networkInfo(network), nil
+}
+
+// CreateNetwork writes the conflist of a new bridge network.
+// The name, the bridge and the subnet must not be used by another network.
+func (instance *FireCrackerManager) CreateNetwork(networkConfig *configs.CNINetworkConfig) (*NetworkInfo, error) {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	networks, err := cni.ListNetworks(cniConfig.ConfDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := networkConflicts(networkConfig, networks); err != nil {
+		return nil, err
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "network"})
+	rootLogger.Info("creating CNI network", "network", networkConfig.Name, "bridge", networkConfig.Bridge, "subnet", networkConfig.Subnet)
+
+	if _, err := cni.WriteNetwork(cniConfig.ConfDir, networkConfig); err != nil {
+		if errors.Is(err, cni.ErrConfListExists) {
+			return nil, fmt.Errorf("%w: %s", ErrNetworkExists, err)
+		}
+		return nil, err
+	}
+
+	return instance.GetNetwork(networkConfig.Name)
+}
+
+// DeleteNetwork removes the conflist of the network, it is refused while VMs are attached.
+// The bridge device is left on the host, as the CNI bridge plugin does when the last interface is removed.
+func (instance *FireCrackerManager) DeleteNetwork(name string) error {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	network, err := findNetwork(name)
+	if err != nil {
+		return err
+	}
+
+	if vms := registry.ListByNetwork(name); len(vms) > 0 {
+		return fmt.Errorf("%w: %d vm(s) attached to network %s", ErrNetworkInUse, len(vms), name)
+	}
+	if vms := startingVMs.ListByNetwork(name); len(vms) > 0 {
+		return fmt.Errorf("%w: %d vm(s) starting on network %s", ErrNetworkInUse, len(vms), name)
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "network"})
+	rootLogger.Info("deleting CNI network", "network", name, "file", network.File)
+
+	if err := os.Remove(network.File); err != nil {
+		return fmt.Errorf("failed removing conflist %s: %w", network.File, err)
+	}
+	return nil
+}
+
+func findNetwork(name string) (*cni.Network, error) {
+	networks, err := cni.ListNetworks(cniConfig.ConfDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNetworkNotFound, name)
+}
+
+func networkConflicts(networkConfig *configs.CNINetworkConfig, networks []*cni.Network) error {
+	subnets := []*net.IPNet{}
+	for _, cidr := range networkConfig.Subnets() {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("subnet is invalid: %s", err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	for _, network := range networks {
+		if network.Name == networkConfig.Name {
+			return fmt.Errorf("%w: %s", ErrNetworkExists, networkConfig.Name)
+		}
+		if network.Bridge == networkConfig.Bridge {
+			return fmt.Errorf("%w: bridge %s is used by network %s", ErrNetworkExists, networkConfig.Bridge, network.Name)
+		}
+		for _, subnet := range subnets {
+			for _, other := range network.Subnets {
+				if other.Contains(subnet.IP) || subnet.Contains(other.IP) {
+					return fmt.Errorf("%w: %s overlaps %s of network %s", ErrSubnetOverlap, subnet, other, network.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func networkInfo(network *cni.Network) *NetworkInfo {
+	attachedVMs := []string{}
+	for _, vm := range registry.ListByNetwork(network.Name) {
+		attachedVMs = append(attachedVMs, vm.ID())
+	}
+	return &NetworkInfo{
+		Network:     network,
+		AttachedVMs: attachedVMs,
+	}
+}
@@ -0,0 +1,110 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"strconv"
+	"sync"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	ops "github.com/firecracker-microvm/firecracker-go-sdk/client/operations"
+)
+
+var (
+	// ErrDeviceNotFound is returned when a runtime update targets a network interface or drive the VM doesn't have.
+	ErrDeviceNotFound = errors.New("device not found")
+)
+
+// interfacesLock serializes the network interface updates, which record the new rate limiters in the machine configuration.
+var interfacesLock sync.Mutex
+
+// UpdateNetworkInterfaceRateLimiters updates the rate limiters of a network interface of a running VM.
+// The interface ID is the Firecracker interface ID, starting at 1. A nil rate limiter is left unchanged.
+func (instance *FireCrackerManager) UpdateNetworkInterfaceRateLimiters(vmID, ifaceID string, inRateLimiter, outRateLimiter *configs.RateLimiterConfig) error {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "rate-limiter"})
+
+	interfacesLock.Lock()
+	defer interfacesLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	machine := runningVM.StartedMachine.RunningMachine()
+
+	index, err := strconv.Atoi(ifaceID)
+	if err != nil || index < 1 || index > len(machine.Cfg.NetworkInterfaces) {
+		return fmt.Errorf("network interface %s: %w", ifaceID, ErrDeviceNotFound)
+	}
+
+	// not using machine.UpdateGuestNetworkInterfaceRateLimit,
+	// the SDK sends the in rate limiter as the tx one
+	fcClient := firecracker.NewClient(machine.Cfg.SocketPath, nil, false)
+
+	_, err = fcClient.PatchGuestNetworkInterfaceByID(context.Background(), ifaceID, &models.PartialNetworkInterface{
+		IfaceID:       firecracker.String(ifaceID),
+		RxRateLimiter: inRateLimiter.ToModel(),
+		TxRateLimiter: outRateLimiter.ToModel(),
+	})
+	if err != nil {
+		errorMsg := fmt.Errorf("failed updating network interface rate limiters, reason: %s", err)
+		rootLogger.Error(errorMsg.Error(), "vmm-id", vmID, "iface-id", ifaceID)
+		return errorMsg
+	}
+
+	if inRateLimiter != nil {
+		machine.Cfg.NetworkInterfaces[index-1].InRateLimiter = inRateLimiter.ToModel()
+	}
+	if outRateLimiter != nil {
+		machine.Cfg.NetworkInterfaces[index-1].OutRateLimiter = outRateLimiter.ToModel()
+	}
+
+	return nil
+}
+
+// UpdateDriveRateLimiter updates the rate limiter of a drive of a running VM.
+func (instance *FireCrackerManager) UpdateDriveRateLimiter(vmID, driveID string, rateLimiter *configs.RateLimiterConfig) error {
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "rate-limiter"})
+
+	// the drive path updates also patch the drives of the machine configuration
+	drivesLock.Lock()
+	defer drivesLock.Unlock()
+
+	runningVM, err := registry.Get(vmID)
+	if err != nil {
+		return err
+	}
+
+	machine := runningVM.StartedMachine.RunningMachine()
+
+	drive := findDrive(machine.Cfg.Drives, driveID)
+	if drive == nil {
+		return fmt.Errorf("drive %s: %w", driveID, ErrDeviceNotFound)
+	}
+
+	err = machine.UpdateGuestDrive(context.Background(), driveID, "", func(params *ops.PatchGuestDriveByIDParams) {
+		params.Body.RateLimiter = rateLimiter.ToModel()
+	})
+	if err != nil {
+		errorMsg := fmt.Errorf("failed updating drive rate limiter, reason: %s", err)
+		rootLogger.Error(errorMsg.Error(), "vmm-id", vmID, "drive-id", driveID)
+		return errorMsg
+	}
+
+	drive.RateLimiter = rateLimiter.ToModel()
+
+	return nil
+}
+
+func findDrive(drives []models.Drive, driveID string) *models.Drive {
+	for i := range drives {
+		if firecracker.StringValue(drives[i].DriveID) == driveID {
+			return &drives[i]
+		}
+	}
+	return nil
+}
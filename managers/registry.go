@@ -0,0 +1,173 @@
+package managers
+
+import (
+	"errors"
+	"open-fire/configs"
+	"open-fire/pkg/vmm"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrVMNotFound is returned when a VMM is not tracked by this server process.
+	ErrVMNotFound = errors.New("vm not found")
+)
+
+// RunningVM represents a VMM started by this server process.
+type RunningVM struct {
+	StartedMachine  vmm.StartedMachine
+	MachineConfig   *configs.MachineConfig
+	JailingFcConfig *configs.JailingFirecrackerConfig
+}
+
+// ID returns the VMM ID of the running VM.
+func (vm *RunningVM) ID() string {
+	return vm.JailingFcConfig.VMMID()
+}
+
+// VMRegistry keeps track of the VMMs started by this server process.
+type VMRegistry struct {
+	sync.RWMutex
+
+	vms map[string]*RunningVM
+}
+
+// NewVMRegistry returns a new, empty registry.
+func NewVMRegistry() *VMRegistry {
+	return &VMRegistry{
+		vms: map[string]*RunningVM{},
+	}
+}
+
+// Add registers a running VM.
+func (r *VMRegistry) Add(vm *RunningVM) {
+	r.Lock()
+	defer r.Unlock()
+	r.vms[vm.ID()] = vm
+}
+
+// Get returns the running VM with the given ID.
+func (r *VMRegistry) Get(vmID string) (*RunningVM, error) {
+	r.RLock()
+	defer r.RUnlock()
+	vm, ok := r.vms[vmID]
+	if !ok {
+		return nil, ErrVMNotFound
+	}
+	return vm, nil
+}
+
+// List returns all running VMs ordered by ID.
+func (r *VMRegistry) List() []*RunningVM {
+	r.RLock()
+	defer r.RUnlock()
+	result := make([]*RunningVM, 0, len(r.vms))
+	for _, vm := range r.vms {
+		result = append(result, vm)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID() < result[j].ID()
+	})
+	return result
+}
+
+// FindByIP returns the running VM with an interface on the CNI network leasing the IP address.
+func (r *VMRegistry) FindByIP(networkName, ipAddress string) (*RunningVM, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, vm := range r.vms {
+		for _, iface := range vm.MachineConfig.FcNetworkInterfaces {
+			if iface.CNINetworkName != networkName || iface.Result == nil {
+				continue
+			}
+			for _, ip := range iface.Result.IPs() {
+				if ip.String() == ipAddress {
+					return vm, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// FindByNameOrID returns the running VM with the name or, failing that, the ID.
+func (r *VMRegistry) FindByNameOrID(nameOrID string) (*RunningVM, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, vm := range r.vms {
+		if vm.MachineConfig.Name != "" && vm.MachineConfig.Name == nameOrID {
+			return vm, true
+		}
+	}
+	vm, ok := r.vms[nameOrID]
+	return vm, ok
+}
+
+// FindByHostPort returns the running VM with a port mapping conflicting with the given one.
+func (r *VMRegistry) FindByHostPort(hostPort *configs.PortMappingConfig) (*RunningVM, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, vm := range r.vms {
+		for _, iface := range vm.MachineConfig.FcNetworkInterfaces {
+			for _, portMapping := range iface.PortMappings {
+				if portMapping.Conflicts(hostPort) {
+					return vm, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// Remove forgets the running VM with the given ID.
+func (r *VMRegistry) Remove(vmID string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.vms, vmID)
+}
+
+// ListByNetwork returns the running VMs with an interface on the CNI network, ordered by ID.
+func (r *VMRegistry) ListByNetwork(networkName string) []*RunningVM {
+	result := []*RunningVM{}
+	for _, vm := range r.List() {
+		for _, iface := range vm.MachineConfig.FcNetworkInterfaces {
+			if iface.CNINetworkName == networkName {
+				result = append(result, vm)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ListByDrive returns the running VMs with a drive backed by the file, ordered by ID.
+func (r *VMRegistry) ListByDrive(path string) []*RunningVM {
+	result := []*RunningVM{}
+	for _, vm := range r.List() {
+		for _, drivePath := range vm.MachineConfig.DrivePaths() {
+			if sameFile(drivePath, path) {
+				result = append(result, vm)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// sameFile returns true if both paths name the same file, following links.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
@@ -0,0 +1,114 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+var (
+	// ErrInvalidRootfsSize is returned when the requested root filesystem size is smaller than the image.
+	ErrInvalidRootfsSize = errors.New("invalid root filesystem size")
+)
+
+// cloneRootfs creates the writable clone of the root filesystem of the VM in its jail directory, so VMs booting
+// the same image do not share it. The root drive is then backed by the clone and the path is returned.
+func cloneRootfs(rootLogger hclog.Logger, machineConfig *configs.MachineConfig, jailingFcConfig *configs.JailingFirecrackerConfig) (string, error) {
+	basePath := machineConfig.RootDrivePath()
+
+	if _, err := utils.CheckIfExistsAndIsRegular(basePath); err != nil {
+		return "", fmt.Errorf("invalid root drive: %w", err)
+	}
+
+	if err := os.MkdirAll(jailingFcConfig.JailerChrootDirectory(), 0755); err != nil {
+		return "", fmt.Errorf("failed creating jail directory: %w", err)
+	}
+
+	// the drive is hard linked in the jail under its base name, which must not collide with the other drives
+	clonePath := filepath.Join(jailingFcConfig.JailerChrootDirectory(), jailingFcConfig.VMMID()+"-"+filepath.Base(basePath))
+
+	rootLogger.Info("cloning root filesystem", "base", basePath, "clone", clonePath)
+
+	if err := utils.CloneFile(basePath, clonePath); err != nil {
+		os.Remove(clonePath)
+		return "", err
+	}
+
+	if machineConfig.FcRootfsSizeMib != 0 {
+		if err := growRootfs(rootLogger, clonePath, machineConfig.FcRootfsSizeMib); err != nil {
+			os.Remove(clonePath)
+			return "", err
+		}
+	}
+
+	machineConfig.FcRootFSClone = clonePath
+	return clonePath, nil
+}
+
+// growRootfs grows a root filesystem clone and its ext4 filesystem to the size, images are never shrunk.
+func growRootfs(rootLogger hclog.Logger, clonePath string, sizeMib int) error {
+	info, err := os.Stat(clonePath)
+	if err != nil {
+		return err
+	}
+	size := int64(sizeMib) << 20
+	if size < info.Size() {
+		return fmt.Errorf("%w: the image is %d MiB, rootfsSizeMib cannot shrink it", ErrInvalidRootfsSize, info.Size()>>20)
+	}
+	if size == info.Size() {
+		return nil
+	}
+
+	rootLogger.Info("growing root filesystem", "clone", clonePath, "from-mib", info.Size()>>20, "to-mib", sizeMib)
+
+	if err := os.Truncate(clonePath, size); err != nil {
+		return err
+	}
+	if err := utils.ResizeExt4(clonePath); err != nil {
+		return fmt.Errorf("failed resizing the ext4 filesystem: %w", err)
+	}
+	return nil
+}
+
+// retainRootfs moves the root filesystem clone of a stopping VM to the disks directory if it is to be persisted,
+// before the jail directory is removed. It returns the path of the retained disk.
+func retainRootfs(rootLogger hclog.Logger, machineConfig *configs.MachineConfig) (string, error) {
+	if machineConfig.FcRootFSClone == "" || !machineConfig.FcPersistRootfs {
+		return "", nil
+	}
+
+	target := filepath.Join(disksConfig.Dir, filepath.Base(machineConfig.FcRootFSClone))
+
+	rootLogger.Info("retaining root filesystem", "clone", machineConfig.FcRootFSClone, "disk", target)
+
+	if err := os.MkdirAll(disksConfig.Dir, 0755); err != nil {
+		return "", err
+	}
+	err := os.Rename(machineConfig.FcRootFSClone, target)
+	if errors.Is(err, syscall.EXDEV) {
+		err = utils.MoveFile(machineConfig.FcRootFSClone, target)
+	}
+	if err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// retainVMRootfs retains the root filesystem clone of a VM known to the registry, errors are logged
+// as the VM is stopped anyway.
+func retainVMRootfs(rootLogger hclog.Logger, runningVM *RunningVM) string {
+	if runningVM == nil {
+		return ""
+	}
+	retained, err := retainRootfs(rootLogger, runningVM.MachineConfig)
+	if err != nil {
+		rootLogger.Error("failed retaining root filesystem, it is removed with the jail", "reason", err)
+	}
+	return retained
+}
@@ -0,0 +1,255 @@
+package managers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+var (
+	// ErrSeccompFilterNotFound is returned when the filters directory has no custom seccomp filter with the name.
+	ErrSeccompFilterNotFound = errors.New("seccomp filter not found")
+	// ErrSeccompFilterInUse is returned when deleting a custom seccomp filter used by running or starting VMs.
+	ErrSeccompFilterInUse = errors.New("seccomp filter is used by running vms")
+	// ErrInvalidSeccompFilter is returned when a seccomp filter definition does not compile.
+	ErrInvalidSeccompFilter = errors.New("invalid seccomp filter")
+
+	seccompFiltersConfig = configs.NewSeccompFiltersConfig()
+)
+
+// seccompFiltersLock serializes the changes to the custom seccomp filters and their compilation.
+var seccompFiltersLock sync.Mutex
+
+// SeccompFilterInfo is a custom seccomp filter with the VMs using it.
+type SeccompFilterInfo struct {
+	Name    string
+	Path    string
+	BPFPath string
+	UsedBy  []string
+}
+
+// SeccompFiltersDefault returns the filter of the VMs not selecting one.
+func (instance *FireCrackerManager) SeccompFiltersDefault() string {
+	return seccompFiltersConfig.Default
+}
+
+// ListSeccompFilters returns the custom seccomp filters, ordered by name.
+func (instance *FireCrackerManager) ListSeccompFilters() ([]*SeccompFilterInfo, error) {
+	entries, err := os.ReadDir(seccompFiltersConfig.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*SeccompFilterInfo{}, nil
+		}
+		return nil, err
+	}
+	result := []*SeccompFilterInfo{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !entry.Type().IsRegular() || name == entry.Name() {
+			continue
+		}
+		filter, err := seccompFilterInfo(name)
+		if err != nil {
+			continue
+		}
+		result = append(result, filter)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// GetSeccompFilter returns the custom seccomp filter with the given name and its JSON definition.
+func (instance *FireCrackerManager) GetSeccompFilter(name string) (*SeccompFilterInfo, []byte, error) {
+	filter, err := seccompFilterInfo(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := os.ReadFile(filter.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, contents, nil
+}
+
+// PutSeccompFilter creates or replaces a custom seccomp filter, compiled for the host architecture with seccompiler-bin.
+// The definition is only stored if it compiles, the running VMs keep the filter they started with.
+// It returns true if the filter was created.
+func (instance *FireCrackerManager) PutSeccompFilter(name string, contents []byte) (*SeccompFilterInfo, bool, error) {
+	seccompFiltersLock.Lock()
+	defer seccompFiltersLock.Unlock()
+
+	path, err := seccompFiltersConfig.FilterPath(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidSeccompFilter, err)
+	}
+	bpfPath, _ := seccompFiltersConfig.BPFPath(name)
+	if err := validateSeccompFilter(contents); err != nil {
+		return nil, false, err
+	}
+
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	if err := os.MkdirAll(seccompFiltersConfig.Dir, 0755); err != nil {
+		return nil, false, err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, 0644); err != nil {
+		os.Remove(tmpPath)
+		return nil, false, err
+	}
+	defer os.Remove(tmpPath)
+
+	if output, err := compileSeccompFilter(tmpPath, bpfPath); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidSeccompFilter, strings.TrimSpace(output))
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, false, err
+	}
+
+	filter, err := seccompFilterInfo(name)
+	return filter, created, err
+}
+
+// DeleteSeccompFilter removes a custom seccomp filter and its compiled filter, it is refused while running or starting VMs use it.
+func (instance *FireCrackerManager) DeleteSeccompFilter(name string) error {
+	seccompFiltersLock.Lock()
+	defer seccompFiltersLock.Unlock()
+
+	filter, err := seccompFilterInfo(name)
+	if err != nil {
+		return err
+	}
+	if len(filter.UsedBy) > 0 {
+		return fmt.Errorf("%w: %s is used by vms %s", ErrSeccompFilterInUse, name, strings.Join(filter.UsedBy, ", "))
+	}
+	if err := os.Remove(filter.BPFPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(filter.Path)
+}
+
+// resolveSeccompFilter sets the path of the compiled custom seccomp filter of the machine, if it has one.
+// The filter is compiled again when the cached one is missing or older than its definition.
+func resolveSeccompFilter(machineConfig *configs.MachineConfig) error {
+	if !configs.IsCustomSeccompFilter(machineConfig.FcSeccompFilter) {
+		return nil
+	}
+
+	seccompFiltersLock.Lock()
+	defer seccompFiltersLock.Unlock()
+
+	filter, err := seccompFilterInfo(machineConfig.FcSeccompFilter)
+	if err != nil {
+		return err
+	}
+	filterInfo, err := os.Stat(filter.Path)
+	if err != nil {
+		return err
+	}
+	if bpfInfo, err := os.Stat(filter.BPFPath); err != nil || bpfInfo.ModTime().Before(filterInfo.ModTime()) {
+		if output, err := compileSeccompFilter(filter.Path, filter.BPFPath); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidSeccompFilter, strings.TrimSpace(output))
+		}
+	}
+	machineConfig.FcSeccompFilterPath = filter.BPFPath
+	return nil
+}
+
+// placeSeccompFilter copies the compiled custom seccomp filter of the machine in the jail, Firecracker reads it once jailed.
+// The copy keeps the filter of the VM when the cached one is replaced.
+func placeSeccompFilter(rootLogger hclog.Logger, machineConfig *configs.MachineConfig, jailingFcConfig *configs.JailingFirecrackerConfig) (string, error) {
+	contents, err := os.ReadFile(machineConfig.FcSeccompFilterPath)
+	if err != nil {
+		return "", err
+	}
+
+	jailRoot := filepath.Join(jailingFcConfig.JailerChrootDirectory(), "root")
+	if err := os.MkdirAll(jailRoot, 0755); err != nil {
+		return "", err
+	}
+	jailPath := filepath.Join(jailRoot, configs.SeccompFilterJailPath)
+
+	rootLogger.Info("copying seccomp filter in the jail", "filter", machineConfig.FcSeccompFilter, "path", jailPath)
+
+	if err := os.WriteFile(jailPath, contents, 0600); err != nil {
+		os.Remove(jailPath)
+		return "", err
+	}
+	if err := os.Chown(jailPath, jailingFcConfig.JailerUID, jailingFcConfig.JailerGID); err != nil {
+		os.Remove(jailPath)
+		return "", err
+	}
+	return jailPath, nil
+}
+
+// validateSeccompFilter checks the definition is a JSON object with a filter for every Firecracker thread,
+// seccompiler-bin validates the filters themselves.
+func validateSeccompFilter(contents []byte) error {
+	filters := map[string]json.RawMessage{}
+	if err := json.NewDecoder(bytes.NewReader(contents)).Decode(&filters); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSeccompFilter, err)
+	}
+	for _, thread := range configs.SeccompFilterThreads {
+		if _, ok := filters[thread]; !ok {
+			return fmt.Errorf("%w: the definition has no filter for the %s thread, it needs %s",
+				ErrInvalidSeccompFilter, thread, strings.Join(configs.SeccompFilterThreads, ", "))
+		}
+	}
+	return nil
+}
+
+// compileSeccompFilter compiles a filter definition for the host architecture, replacing the compiled filter
+// only if it succeeds. It returns the seccompiler-bin output.
+func compileSeccompFilter(path, bpfPath string) (string, error) {
+	tmpBPFPath := bpfPath + ".tmp"
+	output, err := utils.RunCommandCombinedOutput(seccompFiltersConfig.Compiler,
+		"--input-file", path, "--target-arch", configs.HostArch(), "--output-file", tmpBPFPath)
+	if err != nil {
+		os.Remove(tmpBPFPath)
+		if output == "" {
+			output = err.Error()
+		}
+		return output, err
+	}
+	return output, os.Rename(tmpBPFPath, bpfPath)
+}
+
+func seccompFilterInfo(name string) (*SeccompFilterInfo, error) {
+	path, err := seccompFiltersConfig.FilterPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSeccompFilterNotFound, err)
+	}
+	if _, err := utils.CheckIfExistsAndIsRegular(path); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSeccompFilterNotFound, name)
+	}
+	bpfPath, _ := seccompFiltersConfig.BPFPath(name)
+	filter := &SeccompFilterInfo{
+		Name:    name,
+		Path:    path,
+		BPFPath: bpfPath,
+		UsedBy:  []string{},
+	}
+	for _, vm := range registry.List() {
+		if vm.MachineConfig.FcSeccompFilter == name {
+			filter.UsedBy = append(filter.UsedBy, vm.ID())
+		}
+	}
+	// a starting VM resolves its filter once tracked, it must not be deleted before the VM copies it in its jail
+	filter.UsedBy = append(filter.UsedBy, startingVMs.Find(func(machineConfig *configs.MachineConfig) bool {
+		return machineConfig.FcSeccompFilter == name
+	})...)
+	return filter, nil
+}
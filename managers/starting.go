@@ -0,0 +1,166 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/utils"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// startingVMs tracks the VMs started by StartVM until they are in the registry or failed to start,
+// so the resources they use cannot be removed in the meantime.
+var startingVMs = newStartingVMSet()
+
+type startingVMSet struct {
+	sync.Mutex
+
+	vms map[string]*configs.MachineConfig
+}
+
+func newStartingVMSet() *startingVMSet {
+	return &startingVMSet{
+		vms: map[string]*configs.MachineConfig{},
+	}
+}
+
+// Reserve tracks a starting VM, unless it conflicts with a running VM or another starting VM.
+// The check and the tracking are atomic, so two VMs requesting the same name, static IP or host port cannot both start.
+func (s *startingVMSet) Reserve(vmmID string, machineConfig *configs.MachineConfig) error {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.conflicts(machineConfig); err != nil {
+		return err
+	}
+	s.vms[vmmID] = machineConfig
+	return nil
+}
+
+// Remove forgets a starting VM, once it is in the registry or failed to start.
+func (s *startingVMSet) Remove(vmmID string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.vms, vmmID)
+}
+
+// Find returns the IDs of the starting VMs matching, ordered.
+func (s *startingVMSet) Find(match func(machineConfig *configs.MachineConfig) bool) []string {
+	s.Lock()
+	defer s.Unlock()
+	result := []string{}
+	for vmmID, machineConfig := range s.vms {
+		if match(machineConfig) {
+			result = append(result, vmmID)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ListByNetwork returns the IDs of the starting VMs with an interface on the CNI network, ordered.
+func (s *startingVMSet) ListByNetwork(networkName string) []string {
+	s.Lock()
+	defer s.Unlock()
+	result := []string{}
+	for vmmID, machineConfig := range s.vms {
+		for _, iface := range machineConfig.FcNetworkInterfaces {
+			if iface.CNINetworkName == networkName {
+				result = append(result, vmmID)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ListByDrive returns the IDs of the starting VMs with a drive backed by the file, ordered.
+func (s *startingVMSet) ListByDrive(path string) []string {
+	s.Lock()
+	defer s.Unlock()
+	result := []string{}
+	for vmmID, machineConfig := range s.vms {
+		for _, drivePath := range machineConfig.DrivePaths() {
+			if sameFile(drivePath, path) {
+				result = append(result, vmmID)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// conflicts returns an error if the VM requests the name, a static IP or a host port of a running VM or another
+// starting VM, the lock is held.
+func (s *startingVMSet) conflicts(machineConfig *configs.MachineConfig) error {
+	if err := s.nameConflicts(machineConfig); err != nil {
+		return err
+	}
+	if err := s.ipConflicts(machineConfig); err != nil {
+		return err
+	}
+	return s.hostPortConflicts(machineConfig)
+}
+
+func (s *startingVMSet) nameConflicts(machineConfig *configs.MachineConfig) error {
+	if machineConfig.Name == "" {
+		return nil
+	}
+	if runningVM, ok := registry.FindByNameOrID(machineConfig.Name); ok {
+		return fmt.Errorf("%w: %s is used by vm %s", ErrNameConflict, machineConfig.Name, runningVM.ID())
+	}
+	for vmmID, other := range s.vms {
+		if other.Name == machineConfig.Name || vmmID == machineConfig.Name {
+			return fmt.Errorf("%w: %s is used by starting vm %s", ErrNameConflict, machineConfig.Name, vmmID)
+		}
+	}
+	return nil
+}
+
+func (s *startingVMSet) ipConflicts(machineConfig *configs.MachineConfig) error {
+	for _, iface := range machineConfig.FcNetworkInterfaces {
+		if iface.IPAddress == "" {
+			continue
+		}
+		if runningVM, ok := registry.FindByIP(iface.CNINetworkName, iface.IPAddress); ok {
+			return fmt.Errorf("%w: %s on network %s is used by vm %s", ErrIPConflict, iface.IPAddress, iface.CNINetworkName, runningVM.ID())
+		}
+		for vmmID, other := range s.vms {
+			for _, otherIface := range other.FcNetworkInterfaces {
+				if otherIface.CNINetworkName == iface.CNINetworkName && otherIface.IPAddress == iface.IPAddress {
+					return fmt.Errorf("%w: %s on network %s is requested by starting vm %s", ErrIPConflict, iface.IPAddress, iface.CNINetworkName, vmmID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *startingVMSet) hostPortConflicts(machineConfig *configs.MachineConfig) error {
+	for _, iface := range machineConfig.FcNetworkInterfaces {
+		for _, portMapping := range iface.PortMappings {
+			if runningVM, ok := registry.FindByHostPort(portMapping); ok {
+				return fmt.Errorf("%w: %s is mapped by vm %s", ErrPortConflict, portMapping.Key(), runningVM.ID())
+			}
+			for vmmID, other := range s.vms {
+				for _, otherIface := range other.FcNetworkInterfaces {
+					for _, otherMapping := range otherIface.PortMappings {
+						if otherMapping.Conflicts(portMapping) {
+							return fmt.Errorf("%w: %s is mapped by starting vm %s", ErrPortConflict, portMapping.Key(), vmmID)
+						}
+					}
+				}
+			}
+			if err := utils.CheckPortAvailable(portMapping.Protocol, portMapping.HostIP, portMapping.HostPort); err != nil {
+				if errors.Is(err, syscall.EADDRNOTAVAIL) {
+					return fmt.Errorf("%w: hostIP %s is not an address of the host", ErrInvalidHostIP, portMapping.HostIP)
+				}
+				return fmt.Errorf("%w: %s is used by another process", ErrPortConflict, portMapping.Key())
+			}
+		}
+	}
+	return nil
+}
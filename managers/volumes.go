@@ -0,0 +1,264 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/utils"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	// ErrVolumeNotFound is returned when the volumes directory has no volume with the name.
+	ErrVolumeNotFound = errors.New("volume not found")
+	// ErrVolumeExists is returned when creating a volume with the name of an existing one.
+	ErrVolumeExists = errors.New("volume already exists")
+	// ErrVolumeInUse is returned when a volume attached read-write is attached again,
+	// or when resizing or deleting an attached volume.
+	ErrVolumeInUse = errors.New("volume is in use")
+	// ErrInvalidVolume is returned when a volume reference or a resize cannot be used.
+	ErrInvalidVolume = errors.New("invalid volume")
+
+	volumesConfig = configs.NewVolumesConfig()
+)
+
+// volumesLock serializes the creations, resizes and deletions of the volumes.
+var volumesLock sync.Mutex
+
+// VolumeInfo is a volume of the volumes directory with the VMs it is attached to.
+type VolumeInfo struct {
+	Name        string
+	Path        string
+	SizeMib     int64
+	AttachedVMs []string
+	ReadWrite   bool
+}
+
+// ListVolumes returns the volumes of the volumes directory, ordered by name.
+func (instance *FireCrackerManager) ListVolumes() ([]*VolumeInfo, error) {
+	entries, err := os.ReadDir(volumesConfig.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*VolumeInfo{}, nil
+		}
+		return nil, err
+	}
+	result := []*VolumeInfo{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		volume, err := volumeInfo(entry.Name())
+		if err != nil {
+			continue
+		}
+		result = append(result, volume)
+	}
+	return result, nil
+}
+
+// GetVolume returns the volume with the given name.
+func (instance *FireCrackerManager) GetVolume(name string) (*VolumeInfo, error) {
+	return volumeInfo(name)
+}
+
+// CreateVolume creates a volume file of the given size formatted with ext4.
+func (instance *FireCrackerManager) CreateVolume(volumeConfig *configs.VolumeConfig) (*VolumeInfo, error) {
+	volumesLock.Lock()
+	defer volumesLock.Unlock()
+
+	path, err := volumesConfig.VolumePath(volumeConfig.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVolume, err)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeExists, volumeConfig.Name)
+	}
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "volumes"})
+	rootLogger.Info("creating volume", "volume", volumeConfig.Name, "size-mib", volumeConfig.SizeMib)
+
+	if err := os.MkdirAll(volumesConfig.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := utils.CreateRootFSFile(path, volumeConfig.SizeMib); err != nil {
+		os.Remove(path)
+		errorMsg := fmt.Errorf("failed creating volume file %s, reason: %s", volumeConfig.Name, err)
+		rootLogger.Error(errorMsg.Error())
+		return nil, errorMsg
+	}
+	if err := utils.MkfsExt4(path); err != nil {
+		os.Remove(path)
+		errorMsg := fmt.Errorf("failed creating ext4 filesystem on volume %s, reason: %s", volumeConfig.Name, err)
+		rootLogger.Error(errorMsg.Error())
+		return nil, errorMsg
+	}
+
+	return volumeInfo(volumeConfig.Name)
+}
+
+// ResizeVolume grows a volume and its ext4 filesystem, it is refused while the volume is attached.
+func (instance *FireCrackerManager) ResizeVolume(name string, sizeMib int) (*VolumeInfo, error) {
+	volumesLock.Lock()
+	defer volumesLock.Unlock()
+
+	volume, err := volumeInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	if int64(sizeMib) < volume.SizeMib {
+		return nil, fmt.Errorf("%w: %s is %d MiB, volumes can only grow", ErrInvalidVolume, name, volume.SizeMib)
+	}
+
+	lock, err := flockVolume(volume, true)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "volumes"})
+	rootLogger.Info("resizing volume", "volume", name, "from-mib", volume.SizeMib, "to-mib", sizeMib)
+
+	// the filesystem is checked before the file grows, so a failed check leaves the volume as it was
+	if err := utils.CheckExt4(volume.Path); err != nil {
+		errorMsg := fmt.Errorf("failed checking the filesystem of volume %s, reason: %s", name, err)
+		rootLogger.Error(errorMsg.Error())
+		return nil, errorMsg
+	}
+	if err := os.Truncate(volume.Path, int64(sizeMib)<<20); err != nil {
+		return nil, err
+	}
+	// resize2fs may have started growing the filesystem, the file is not shrunk back under it;
+	// resizing to the same size grows the filesystem again
+	if err := utils.GrowExt4(volume.Path); err != nil {
+		errorMsg := fmt.Errorf("volume %s was grown to %d MiB but its filesystem was not, resize it to %d MiB again to retry, reason: %s",
+			name, sizeMib, sizeMib, err)
+		rootLogger.Error(errorMsg.Error())
+		return nil, errorMsg
+	}
+
+	return volumeInfo(name)
+}
+
+// DeleteVolume removes a volume, it is refused while the volume is attached.
+func (instance *FireCrackerManager) DeleteVolume(name string) error {
+	volumesLock.Lock()
+	defer volumesLock.Unlock()
+
+	volume, err := volumeInfo(name)
+	if err != nil {
+		return err
+	}
+	lock, err := flockVolume(volume, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	rootLogger := logConfig.NewLogger(configs.LoggerOpts{Name: "volumes"})
+	rootLogger.Info("deleting volume", "volume", name)
+
+	return os.Remove(volume.Path)
+}
+
+// ResolveVolumeReferences replaces the volume names of the additional drives of a create request with their paths.
+func (instance *FireCrackerManager) ResolveVolumeReferences(createVM *requests.CreateVMRequest) error {
+	for i := range createVM.AdditionalDrives {
+		drive := &createVM.AdditionalDrives[i]
+		if drive.Volume == "" {
+			continue
+		}
+		if drive.Path != "" {
+			return fmt.Errorf("%w: volume and path cannot both be given", ErrInvalidVolume)
+		}
+		volume, err := volumeInfo(drive.Volume)
+		if err != nil {
+			return err
+		}
+		drive.Path = volume.Path
+	}
+	return nil
+}
+
+// lockVolumes locks the volumes attached to the machine until the VM exits, exclusively
+// for the volumes attached read-write and shared for the ones attached read-only.
+func lockVolumes(machineConfig *configs.MachineConfig) ([]*os.File, error) {
+	locks := []*os.File{}
+	for _, drive := range machineConfig.FcAdditionalDrives {
+		name, ok := volumesConfig.VolumeName(drive.Path)
+		if !ok {
+			continue
+		}
+		volume, err := volumeInfo(name)
+		if err != nil {
+			unlockVolumes(locks)
+			return nil, err
+		}
+		lock, err := flockVolume(volume, !drive.ReadOnly)
+		if err != nil {
+			unlockVolumes(locks)
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func unlockVolumes(locks []*os.File) {
+	for _, lock := range locks {
+		lock.Close()
+	}
+}
+
+// flockVolume takes a lock on the volume file, released when the returned file is closed.
+func flockVolume(volume *VolumeInfo, exclusive bool) (*os.File, error) {
+	file, err := os.Open(volume.Path)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if len(volume.AttachedVMs) == 0 {
+				return nil, fmt.Errorf("%w: %s is locked by another process", ErrVolumeInUse, volume.Name)
+			}
+			return nil, fmt.Errorf("%w: %s is attached to vms %s", ErrVolumeInUse, volume.Name, strings.Join(volume.AttachedVMs, ", "))
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func volumeInfo(name string) (*VolumeInfo, error) {
+	path, err := volumesConfig.VolumePath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeNotFound, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeNotFound, name)
+	}
+	volume := &VolumeInfo{
+		Name:        name,
+		Path:        path,
+		SizeMib:     info.Size() >> 20,
+		AttachedVMs: []string{},
+	}
+	for _, vm := range registry.ListByDrive(path) {
+		volume.AttachedVMs = append(volume.AttachedVMs, vm.ID())
+		for _, drive := range vm.MachineConfig.FcAdditionalDrives {
+			if !drive.ReadOnly && sameFile(drive.Path, path) {
+				volume.ReadWrite = true
+			}
+		}
+	}
+	return volume, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"open-fire/configs"
+	"open-fire/managers"
+)
+
+func getMetadataRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, _ []string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	contents, err := fcManager.GetMetadata(vmID)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	writeJSONResponse(w, 200, contents)
+}
+
+func putMetadataRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, _ []string) {
+
+	metadata, ok := readMetadataBody(w, r)
+	if !ok {
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.PutMetadata(vmID, metadata); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func patchMetadataRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, _ []string) {
+
+	patch, ok := readMetadataBody(w, r)
+	if !ok {
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.PatchMetadata(vmID, patch); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// readMetadataBody reads the metadata JSON object of the body, the error response is written if ok is false.
+func readMetadataBody(w http.ResponseWriter, r *http.Request) (*configs.MetadataConfig, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, configs.MetadataMaxSizeBytes)
+
+	var raw json.RawMessage
+	if err := readJSONBody(r, &raw); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return nil, false
+	}
+
+	metadata, err := configs.NewMetadataConfigFromRequest(raw)
+	if err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return nil, false
+	}
+
+	return metadata, true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"strings"
+)
+
+// networksRequestHandler dispatches the requests made to /v1/networks and /v1/networks/{name}.
+func networksRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/networks"), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		listNetworksRequestHandler(w, r)
+	case name == "" && r.Method == http.MethodPost:
+		createNetworkRequestHandler(w, r)
+	case name != "" && strings.Contains(name, "/"):
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	case name != "" && r.Method == http.MethodGet:
+		getNetworkRequestHandler(w, r, name)
+	case name != "" && r.Method == http.MethodDelete:
+		deleteNetworkRequestHandler(w, r, name)
+	default:
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+	}
+}
+
+func listNetworksRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	networks, err := fcManager.ListNetworks()
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListNetworksResponse{
+		Networks: []response.NetworkResponse{},
+	}
+	for _, network := range networks {
+		resp.Networks = append(resp.Networks, networkResponse(network))
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+func getNetworkRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	network, err := fcManager.GetNetwork(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := networkResponse(network)
+	writeJSONResponse(w, 200, &resp)
+}
+
+func createNetworkRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	var req requests.CreateNetworkRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	networkConfig := configs.NewCNINetworkConfigFromRequest(&req)
+	if err := networkConfig.Validate(); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	network, err := fcManager.CreateNetwork(networkConfig)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := networkResponse(network)
+	writeJSONResponse(w, 201, &resp)
+}
+
+func deleteNetworkRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.DeleteNetwork(name); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func networkResponse(network *managers.NetworkInfo) response.NetworkResponse {
+	subnets := []string{}
+	for _, subnet := range network.Subnets {
+		subnets = append(subnets, subnet.String())
+	}
+	return response.NetworkResponse{
+		Name:        network.Name,
+		File:        network.File,
+		Bridge:      network.Bridge,
+		Subnets:     subnets,
+		Masquerade:  network.Masquerade,
+		Plugins:     network.Plugins,
+		AttachedVMs: network.AttachedVMs,
+	}
+}
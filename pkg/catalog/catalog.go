@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry is a kernel or a root filesystem of the catalog.
+type Entry struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version,omitempty"`
+	Arch        string    `json:"arch"`
+	Description string    `json:"description,omitempty"`
+	Path        string    `json:"path"`
+	SHA256      string    `json:"sha256"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	Uploaded    bool      `json:"uploaded"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ID returns the reference of the entry, name:version or the name of an unversioned entry.
+func (e *Entry) ID() string {
+	if e.Version == "" {
+		return e.Name
+	}
+	return e.Name + ":" + e.Version
+}
+
+// Load reads the entries of an index, a missing index is an empty catalog.
+func Load(indexPath string) ([]*Entry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Entry{}, nil
+		}
+		return nil, err
+	}
+	entries := []*Entry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Save writes the entries of an index, ordered by name then registration.
+func Save(indexPath string, entries []*Entry) error {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return err
+	}
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, indexPath)
+}
+
+// Find returns the entries matching a reference, the most recently registered first.
+// A name:version reference matches one entry, a name all the versions of the name.
+func Find(entries []*Entry, ref string) []*Entry {
+	result := []*Entry{}
+	for _, entry := range entries {
+		if entry.ID() == ref || entry.Name == ref {
+			result = append(result, entry)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Checksum returns the SHA-256 checksum and the size of a file.
+func Checksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Store writes the contents of the reader to a new file and returns its SHA-256 checksum and size.
+// The file only appears at the path once complete.
+func Store(path string, contents io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,50 @@
+package cloudinit
+
+import (
+	"fmt"
+	"open-fire/utils"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// SeedLabel is the file system label the NoCloud datasource looks for.
+	SeedLabel = "CIDATA"
+	// seedSizeMib fits the user data and the keys with room to spare.
+	seedSizeMib = 2
+)
+
+// CreateNoCloudSeed creates a FAT image labeled CIDATA at the path, holding the user-data and meta-data files.
+// cloud-init only detects NoCloud seeds on vfat or iso9660 file systems.
+func CreateNoCloudSeed(path string, userData, metaData []byte) error {
+	workDir, err := os.MkdirTemp("", "cidata")
+	if err != nil {
+		return fmt.Errorf("failed creating seed work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	files := map[string][]byte{
+		"user-data": userData,
+		"meta-data": metaData,
+	}
+
+	if err := utils.CreateRootFSFile(path, seedSizeMib); err != nil {
+		return fmt.Errorf("failed creating seed image: %w", err)
+	}
+	if err := utils.MkfsVfat(path, SeedLabel); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed formatting seed image: %w", err)
+	}
+	for name, contents := range files {
+		source := filepath.Join(workDir, name)
+		if err := os.WriteFile(source, contents, 0600); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed writing %s: %w", name, err)
+		}
+		if err := utils.CopyToVfatImage(path, source, name); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed copying %s to seed image: %w", name, err)
+		}
+	}
+	return nil
+}
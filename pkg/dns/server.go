@@ -0,0 +1,223 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"open-fire/configs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// maxPacketSize is large enough for the EDNS responses of the upstreams.
+	maxPacketSize = 4096
+	// forwardTimeout is the time to wait for an upstream before trying the next one.
+	forwardTimeout = 2 * time.Second
+)
+
+// Resolver returns the IPs of the VM with the name or ID on the network, found is false if there is no such VM.
+type Resolver func(nameOrID, network string) (ips []net.IP, found bool)
+
+// Server answers the queries for <vm>.<network>.<domain> from the resolver and, on the gateways forwarding them,
+// forwards the other ones upstream. It listens on UDP on the gateway of the bridges, which the guests use as their nameserver.
+type Server struct {
+	sync.Mutex
+
+	logger    hclog.Logger
+	config    *configs.DNSConfig
+	resolver  Resolver
+	listeners map[string]net.PacketConn
+	// queries holds a token per query being answered, bounding the goroutines and the upstream requests
+	queries chan struct{}
+}
+
+// NewServer returns a new server, listening on no address yet.
+func NewServer(logger hclog.Logger, config *configs.DNSConfig, resolver Resolver) *Server {
+	return &Server{
+		logger:    logger,
+		config:    config,
+		resolver:  resolver,
+		listeners: map[string]net.PacketConn{},
+		queries:   make(chan struct{}, config.MaxConcurrentQueries),
+	}
+}
+
+// Listen starts answering on the gateway address, unless already listening on it. The queries outside of the domain
+// are forwarded upstream if forward is true, else refused so the guests ask their next nameserver.
+// The listeners live as long as the process, like the bridges outlive the VMs.
+func (s *Server) Listen(gateway net.IP, forward bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	address := net.JoinHostPort(gateway.String(), fmt.Sprint(s.config.Port))
+	if _, ok := s.listeners[address]; ok {
+		return nil
+	}
+
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("failed listening on %s: %w", address, err)
+	}
+	s.listeners[address] = conn
+
+	s.logger.Info("dns resolver listening", "address", address, "domain", s.config.Domain, "forward", forward)
+
+	go s.serve(conn, forward)
+	return nil
+}
+
+func (s *Server) serve(conn net.PacketConn, forward bool) {
+	for {
+		buf := make([]byte, maxPacketSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.logger.Error("dns resolver stopped", "address", conn.LocalAddr().String(), "reason", err)
+			return
+		}
+		// wait for a token, the packets arriving meanwhile queue in the socket buffer and the excess is dropped
+		s.queries <- struct{}{}
+		go func() {
+			defer func() { <-s.queries }()
+			response := s.handle(buf[:n], forward)
+			if response == nil {
+				return
+			}
+			if _, err := conn.WriteTo(response, addr); err != nil {
+				s.logger.Warn("failed writing dns response", "client", addr.String(), "reason", err)
+			}
+		}()
+	}
+}
+
+// handle returns the response to the query packet, nil if the packet cannot be parsed.
+func (s *Server) handle(packet []byte, forward bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(packet)
+	if err != nil {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+
+	nameOrID, network, ok := s.splitName(question.Name.String())
+	if !ok {
+		if !forward {
+			return s.reply(header, question, dnsmessage.RCodeRefused, nil)
+		}
+		response, err := s.forward(packet)
+		if err != nil {
+			s.logger.Warn("failed forwarding dns query", "name", question.Name.String(), "reason", err)
+			return s.reply(header, question, dnsmessage.RCodeServerFailure, nil)
+		}
+		return response
+	}
+
+	ips, found := s.resolver(nameOrID, network)
+	if !found {
+		return s.reply(header, question, dnsmessage.RCodeNameError, nil)
+	}
+	return s.reply(header, question, dnsmessage.RCodeSuccess, ips)
+}
+
+// splitName returns the VM and network parts of <vm>.<network>.<domain>.
+// ok is false for the names outside of the domain, which are forwarded.
+func (s *Server) splitName(name string) (nameOrID, network string, ok bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain := strings.ToLower(s.config.Domain)
+	if name != domain && !strings.HasSuffix(name, "."+domain) {
+		return "", "", false
+	}
+	labels := strings.Split(strings.TrimSuffix(strings.TrimSuffix(name, domain), "."), ".")
+	if len(labels) != 2 {
+		// in the domain but not a VM name, answered as missing
+		return "", "", true
+	}
+	return labels[0], labels[1], true
+}
+
+// reply builds an authoritative response with the records of the IPs matching the question type.
+func (s *Server) reply(header dnsmessage.Header, question dnsmessage.Question, rcode dnsmessage.RCode, ips []net.IP) []byte {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		Authoritative:      rcode != dnsmessage.RCodeServerFailure,
+		RecursionDesired:   header.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	builder.EnableCompression()
+
+	if err := builder.StartQuestions(); err != nil {
+		return nil
+	}
+	if err := builder.Question(question); err != nil {
+		return nil
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil
+	}
+
+	resourceHeader := dnsmessage.ResourceHeader{
+		Name:  question.Name,
+		Class: dnsmessage.ClassINET,
+		TTL:   s.config.TTL,
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil && question.Type == dnsmessage.TypeA {
+			var a [4]byte
+			copy(a[:], ip4)
+			if err := builder.AResource(resourceHeader, dnsmessage.AResource{A: a}); err != nil {
+				return nil
+			}
+		} else if ip.To4() == nil && question.Type == dnsmessage.TypeAAAA {
+			var aaaa [16]byte
+			copy(aaaa[:], ip.To16())
+			if err := builder.AAAAResource(resourceHeader, dnsmessage.AAAAResource{AAAA: aaaa}); err != nil {
+				return nil
+			}
+		}
+	}
+
+	response, err := builder.Finish()
+	if err != nil {
+		return nil
+	}
+	return response
+}
+
+// forward relays the query to the upstreams in turn and returns the first response.
+func (s *Server) forward(packet []byte) ([]byte, error) {
+	var lastErr error
+	for _, upstream := range s.config.UpstreamServers() {
+		response, err := exchange(upstream, packet)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func exchange(upstream string, packet []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", upstream, forwardTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,137 @@
+package rootfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// InitPath is the path of the generated init in the guest.
+	InitPath = "/sbin/open-fire-init"
+	// AgentPath is the path of the guest agent in the guest, started by the init when present.
+	AgentPath = "/usr/local/bin/open-fire-agent"
+)
+
+// InstallInit writes the init running the command of the image, and the guest agent if the agent binary is given.
+// /sbin/init points to the generated init unless the image has its own.
+func InstallInit(dir string, imageConfig *ImageConfig, agentBinary string) error {
+	shell, err := secureJoin(dir, "/bin/sh")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(shell); err != nil {
+		return fmt.Errorf("the image has no /bin/sh to run the init")
+	}
+
+	if err := writeGuestFile(dir, InitPath, initScript(imageConfig), 0755); err != nil {
+		return err
+	}
+
+	if agentBinary != "" {
+		agent, err := os.ReadFile(agentBinary)
+		if err != nil {
+			return fmt.Errorf("failed reading the guest agent: %w", err)
+		}
+		if err := writeGuestFile(dir, AgentPath, agent, 0755); err != nil {
+			return err
+		}
+	}
+
+	sbin, err := secureJoin(dir, "/sbin")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(filepath.Join(sbin, "init")); os.IsNotExist(err) {
+		return os.Symlink(filepath.Base(InitPath), filepath.Join(sbin, "init"))
+	}
+	return nil
+}
+
+// UsedBytes returns the space the files of a directory take in a filesystem, counting a block per entry
+// for the inodes and the partially used blocks.
+func UsedBytes(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		total += 4096
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// initScript returns the init of the guest: it mounts the pseudo filesystems, starts the guest agent,
+// runs the command of the image with its environment and reboots, which stops the VM, when it exits.
+func initScript(imageConfig *ImageConfig) []byte {
+	script := &bytes.Buffer{}
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("# generated by open-fire from the image configuration\n")
+	script.WriteString("mount -t proc proc /proc\n")
+	script.WriteString("mount -t sysfs sysfs /sys\n")
+	script.WriteString("mount -t devtmpfs devtmpfs /dev\n")
+	script.WriteString("mkdir -p /dev/pts /dev/shm /run /tmp\n")
+	script.WriteString("mount -t devpts devpts /dev/pts\n")
+	script.WriteString("mount -t tmpfs tmpfs /dev/shm\n")
+	script.WriteString("mount -t tmpfs tmpfs /run\n")
+	fmt.Fprintf(script, "[ -x %s ] && %s &\n", AgentPath, AgentPath)
+
+	for _, env := range imageConfig.Config.Env {
+		if name, value, ok := strings.Cut(env, "="); ok && isShellName(name) {
+			fmt.Fprintf(script, "export %s=%s\n", name, shellQuote(value))
+		}
+	}
+	if imageConfig.Config.WorkingDir != "" {
+		fmt.Fprintf(script, "cd %s\n", shellQuote(imageConfig.Config.WorkingDir))
+	}
+
+	command := imageConfig.Command()
+	if len(command) == 0 {
+		command = []string{"/bin/sh"}
+	}
+	quoted := []string{}
+	for _, arg := range command {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	script.WriteString(strings.Join(quoted, " ") + "\n")
+
+	script.WriteString("sync\n")
+	script.WriteString("echo b > /proc/sysrq-trigger\n")
+	return script.Bytes()
+}
+
+func writeGuestFile(dir, guestPath string, contents []byte, mode os.FileMode) error {
+	target, err := secureJoin(dir, guestPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, contents, mode); err != nil {
+		return err
+	}
+	return os.Chmod(target, mode)
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func isShellName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
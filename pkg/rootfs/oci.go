@@ -0,0 +1,333 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+	maxSymlinks    = 255
+)
+
+// ImageConfig is the part of the image configuration the generated init needs.
+type ImageConfig struct {
+	Architecture string `json:"architecture"`
+	Config       struct {
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+		Env        []string `json:"Env"`
+		WorkingDir string   `json:"WorkingDir"`
+	} `json:"config"`
+}
+
+// Command returns the command of the image, the entrypoint followed by the cmd.
+func (c *ImageConfig) Command() []string {
+	return append(append([]string{}, c.Config.Entrypoint...), c.Config.Cmd...)
+}
+
+// Arch returns the architecture of the image, named like uname -m.
+func (c *ImageConfig) Arch() string {
+	switch c.Architecture {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	default:
+		return c.Architecture
+	}
+}
+
+type archiveManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// ExtractImage flattens the layers of an image of a docker save archive in a directory and returns its configuration.
+// The tag selects the image of an archive holding several, the first one is used if empty.
+func ExtractImage(archivePath, tag, dir string) (*ImageConfig, error) {
+	manifests := []archiveManifest{}
+	if err := readArchiveJSON(archivePath, "manifest.json", &manifests); err != nil {
+		return nil, fmt.Errorf("not a docker save archive: %w", err)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("the archive has no image")
+	}
+
+	manifest := manifests[0]
+	if tag != "" {
+		found := false
+		for _, candidate := range manifests {
+			for _, repoTag := range candidate.RepoTags {
+				if repoTag == tag {
+					manifest, found = candidate, true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the archive has no image tagged %s", tag)
+		}
+	}
+
+	imageConfig := &ImageConfig{}
+	if err := readArchiveJSON(archivePath, manifest.Config, imageConfig); err != nil {
+		return nil, fmt.Errorf("failed reading the image configuration: %w", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if err := withArchiveEntry(archivePath, layer, func(reader io.Reader) error {
+			return applyLayer(reader, dir)
+		}); err != nil {
+			return nil, fmt.Errorf("failed applying layer %s: %w", layer, err)
+		}
+	}
+
+	return imageConfig, nil
+}
+
+func readArchiveJSON(archivePath, name string, value interface{}) error {
+	return withArchiveEntry(archivePath, name, func(reader io.Reader) error {
+		return json.NewDecoder(reader).Decode(value)
+	})
+}
+
+// withArchiveEntry calls fn with the contents of an entry of the archive, the archive is scanned
+// once per entry so the layers are never held in memory or on disk.
+func withArchiveEntry(archivePath, name string, fn func(io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := tar.NewReader(file)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s not found in the archive", name)
+		}
+		if err != nil {
+			return err
+		}
+		if path.Clean(header.Name) == path.Clean(name) {
+			return fn(archive)
+		}
+	}
+}
+
+// applyLayer extracts a layer tarball, compressed with gzip or not, on top of the directory
+// and applies its whiteouts to the previous layers.
+func applyLayer(reader io.Reader, dir string) error {
+	buffered := bufio.NewReader(reader)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	} else {
+		reader = buffered
+	}
+
+	// paths written by this layer, an opaque whiteout only hides the previous layers
+	written := map[string]bool{}
+
+	layer := tar.NewReader(reader)
+	for {
+		header, err := layer.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + header.Name)
+		if name == "/" {
+			continue
+		}
+		parent, base := path.Split(name)
+
+		target, err := secureJoin(dir, parent)
+		if err != nil {
+			return err
+		}
+
+		if base == whiteoutOpaque {
+			if err := removeChildren(target, written); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			hidden := strings.TrimPrefix(base, whiteoutPrefix)
+			// .wh.. or .wh... would remove the parent directories, the root's included
+			if hidden == "" || hidden == "." || hidden == ".." || strings.Contains(hidden, "/") {
+				return fmt.Errorf("%s: invalid whiteout", name)
+			}
+			if err := os.RemoveAll(filepath.Join(target, hidden)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		target = filepath.Join(target, base)
+		if err := extractEntry(layer, header, dir, target); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		written[target] = true
+	}
+}
+
+func extractEntry(layer *tar.Reader, header *tar.Header, dir, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	// an entry replaces whatever the previous layers had at its path, except a directory by a directory
+	if info, err := os.Lstat(target); err == nil && !(info.IsDir() && header.Typeflag == tar.TypeDir) {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	mode := os.FileMode(header.Mode).Perm()
+	if header.Mode&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if header.Mode&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if header.Mode&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, layer)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return err
+		}
+		return os.Lchown(target, header.Uid, header.Gid)
+	case tar.TypeLink:
+		linkParent, linkBase := path.Split(path.Clean("/" + header.Linkname))
+		source, err := secureJoin(dir, linkParent)
+		if err != nil {
+			return err
+		}
+		return os.Link(filepath.Join(source, linkBase), target)
+	case tar.TypeFifo:
+		if err := syscall.Mkfifo(target, uint32(header.Mode&07777)); err != nil {
+			return err
+		}
+	case tar.TypeChar, tar.TypeBlock:
+		// the init mounts devtmpfs on /dev, the device nodes of the image are not needed
+		return nil
+	default:
+		return nil
+	}
+
+	// chown clears the setuid and setgid bits, it goes first
+	if err := os.Lchown(target, header.Uid, header.Gid); err != nil {
+		return err
+	}
+	return os.Chmod(target, mode)
+}
+
+// removeChildren removes the children of a directory not written by the current layer.
+func removeChildren(dir string, written map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		child := filepath.Join(dir, entry.Name())
+		if written[child] {
+			continue
+		}
+		if err := os.RemoveAll(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secureJoin joins a path of the image to the root directory, following the symbolic links of the image
+// as the guest would, so an entry cannot be written outside of the root.
+func secureJoin(root, unsafePath string) (string, error) {
+	resolved := ""
+	remaining := path.Clean("/" + unsafePath)
+	links := 0
+
+	for remaining != "" && remaining != "/" {
+		remaining = strings.TrimPrefix(remaining, "/")
+		segment := remaining
+		if i := strings.Index(remaining, "/"); i >= 0 {
+			segment, remaining = remaining[:i], remaining[i:]
+		} else {
+			remaining = ""
+		}
+
+		if segment == "" || segment == "." {
+			continue
+		}
+		if segment == ".." {
+			resolved = path.Dir("/" + resolved)
+			continue
+		}
+
+		candidate := path.Join("/", resolved, segment)
+		info, err := os.Lstat(filepath.Join(root, candidate))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errors.New("too many levels of symbolic links")
+		}
+		linkTarget, err := os.Readlink(filepath.Join(root, candidate))
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(linkTarget) {
+			linkTarget = path.Join("/", resolved, linkTarget)
+		}
+		resolved = ""
+		remaining = path.Clean(linkTarget) + remaining
+	}
+
+	return filepath.Join(root, path.Clean("/"+resolved)), nil
+}
@@ -0,0 +1,253 @@
+package rootfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// layerEntry is an entry of a test layer, a regular file unless the type says otherwise.
+type layerEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	contents string
+}
+
+func layerTar(t *testing.T, entries []layerEntry) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0644,
+			Uid:      os.Getuid(),
+			Gid:      os.Getgid(),
+		}
+		switch entry.typeflag {
+		case 0:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.contents))
+		case tar.TypeDir:
+			header.Mode = 0755
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := writer.Write([]byte(entry.contents)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSecureJoin(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for link, target := range map[string]string{
+		"up":       "../../..",
+		"abs":      outside,
+		"etc-link": "/etc",
+		"loop":     "loop",
+		"rel":      "dir/sub",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain path", path: "usr/bin", want: "usr/bin"},
+		{name: "dot dot entries", path: "../../etc/passwd", want: "etc/passwd"},
+		{name: "dot dot inside", path: "usr/../../../etc", want: "etc"},
+		{name: "relative symlink escape", path: "up/etc/passwd", want: "etc/passwd"},
+		{name: "absolute symlink escape", path: "abs/file", want: filepath.Join(strings.TrimPrefix(outside, "/"), "file")},
+		{name: "absolute symlink", path: "etc-link/hosts", want: "etc/hosts"},
+		{name: "relative symlink", path: "rel/file", want: "dir/sub/file"},
+		{name: "symlink loop", path: "loop/file", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := secureJoin(root, test.path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("secureJoin(%q) = %q, want an error", test.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("secureJoin(%q) failed: %s", test.path, err)
+			}
+			if want := filepath.Join(root, test.want); got != want {
+				t.Fatalf("secureJoin(%q) = %q, want %q", test.path, got, want)
+			}
+		})
+	}
+}
+
+func TestApplyLayer(t *testing.T) {
+	tests := []struct {
+		name string
+		// base is applied before the layer
+		base    []layerEntry
+		layer   []layerEntry
+		wantErr bool
+		// paths relative to the root, that must exist or not once applied
+		exist   []string
+		missing []string
+	}{
+		{
+			name:  "dot dot entries",
+			layer: []layerEntry{{name: "../../escaped", contents: "x"}},
+			exist: []string{"escaped"},
+		},
+		{
+			name: "symlink escape",
+			layer: []layerEntry{
+				{name: "up", typeflag: tar.TypeSymlink, linkname: "../../.."},
+				{name: "up/escaped", contents: "x"},
+			},
+			exist: []string{"escaped"},
+		},
+		{
+			name: "symlinked directory replaced by a file",
+			base: []layerEntry{{name: "dir", typeflag: tar.TypeSymlink, linkname: "/"}},
+			layer: []layerEntry{
+				{name: "dir", contents: "x"},
+			},
+			exist: []string{"dir"},
+		},
+		{
+			name: "hardlink to a symlink",
+			layer: []layerEntry{
+				{name: "passwd-link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+				{name: "hardlink", typeflag: tar.TypeLink, linkname: "passwd-link"},
+			},
+			exist: []string{"hardlink"},
+		},
+		{
+			name: "hardlink escape",
+			layer: []layerEntry{
+				{name: "hardlink", typeflag: tar.TypeLink, linkname: "../../../etc/passwd"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hardlink through a symlink",
+			layer: []layerEntry{
+				{name: "up", typeflag: tar.TypeSymlink, linkname: "../.."},
+				{name: "hardlink", typeflag: tar.TypeLink, linkname: "up/etc/passwd"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "whiteout",
+			base:    []layerEntry{{name: "dir/removed", contents: "x"}, {name: "dir/kept", contents: "x"}},
+			layer:   []layerEntry{{name: "dir/.wh.removed", typeflag: tar.TypeReg}},
+			exist:   []string{"dir/kept"},
+			missing: []string{"dir/removed", "dir/.wh.removed"},
+		},
+		{
+			name: "opaque whiteout",
+			base: []layerEntry{{name: "dir/old", contents: "x"}},
+			layer: []layerEntry{
+				{name: "dir/new", contents: "x"},
+				{name: "dir/.wh..wh..opq", typeflag: tar.TypeReg},
+			},
+			exist:   []string{"dir/new"},
+			missing: []string{"dir/old"},
+		},
+		{
+			name:    "whiteout through a symlink",
+			base:    []layerEntry{{name: "up", typeflag: tar.TypeSymlink, linkname: "../.."}, {name: "kept", contents: "x"}},
+			layer:   []layerEntry{{name: "up/.wh.kept", typeflag: tar.TypeReg}},
+			missing: []string{"kept"},
+		},
+		{
+			name:    "whiteout of the parent",
+			base:    []layerEntry{{name: "dir/kept", contents: "x"}},
+			layer:   []layerEntry{{name: "dir/.wh..", typeflag: tar.TypeReg}},
+			wantErr: true,
+			exist:   []string{"dir/kept"},
+		},
+		{
+			name:    "whiteout of the grandparent",
+			base:    []layerEntry{{name: "kept", contents: "x"}},
+			layer:   []layerEntry{{name: ".wh...", typeflag: tar.TypeReg}},
+			wantErr: true,
+			exist:   []string{"kept"},
+		},
+		{
+			name:    "empty whiteout",
+			base:    []layerEntry{{name: "dir/kept", contents: "x"}},
+			layer:   []layerEntry{{name: "dir/.wh.", typeflag: tar.TypeReg}},
+			wantErr: true,
+			exist:   []string{"dir/kept"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the root is nested so escapes land in the parent, which is checked to stay empty
+			parent := t.TempDir()
+			root := filepath.Join(parent, "rootfs")
+			if err := os.Mkdir(root, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if test.base != nil {
+				if err := applyLayer(layerTar(t, test.base), root); err != nil {
+					t.Fatalf("applying the base layer failed: %s", err)
+				}
+			}
+
+			err := applyLayer(layerTar(t, test.layer), root)
+			if test.wantErr && err == nil {
+				t.Fatal("applyLayer succeeded, want an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("applyLayer failed: %s", err)
+			}
+
+			for _, path := range test.exist {
+				if _, err := os.Lstat(filepath.Join(root, path)); err != nil {
+					t.Errorf("%s should exist: %s", path, err)
+				}
+			}
+			for _, path := range test.missing {
+				if _, err := os.Lstat(filepath.Join(root, path)); !os.IsNotExist(err) {
+					t.Errorf("%s should not exist", path)
+				}
+			}
+			entries, err := os.ReadDir(parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("the layer wrote outside of the root: %v", entries)
+			}
+			if info, err := os.Lstat(filepath.Join(root, "hardlink")); err == nil && info.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("hardlink should link the symlink itself, not its target")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/hashicorp/go-hclog"
+)
+
+// Handler names
+const (
+	CPUConfigName = "fcinit.CPUConfig"
+)
+
+// NewCPUConfigHandler returns a firecracker handler applying a custom CPU template with PUT /cpu-config,
+// which the SDK does not support. It must run after the machine configuration and before the start.
+func NewCPUConfigHandler(logger hclog.Logger, cpuConfigPath string) firecracker.Handler {
+	return firecracker.Handler{
+		Name: CPUConfigName,
+		Fn: func(ctx context.Context, m *firecracker.Machine) error {
+
+			cpuConfig, err := os.ReadFile(cpuConfigPath)
+			if err != nil {
+				logger.Error("error while reading the CPU template", "path", cpuConfigPath, "reason", err)
+				return err
+			}
+
+			if err := putCPUConfig(ctx, m.Cfg.SocketPath, cpuConfig); err != nil {
+				logger.Error("error while setting the CPU template", "path", cpuConfigPath, "reason", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+func putCPUConfig(ctx context.Context, socketPath string, cpuConfig []byte) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://localhost/cpu-config", bytes.NewReader(cpuConfig))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("PUT /cpu-config answered %d: %s", response.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"net"
+	"open-fire/configs"
+	"strconv"
+)
+
+// imdsTree returns the EC2 instance metadata layout of the VM, published in MMDS under the latest key.
+// MMDS serves objects as key listings and strings as values, like IMDS, so every leaf is a string.
+func imdsTree(vmmID string, machineConfig *configs.MachineConfig) map[string]interface{} {
+	hostname := vmmID
+	if machineConfig.Name != "" {
+		hostname = machineConfig.Name
+	}
+
+	metaData := map[string]interface{}{
+		"instance-id":    vmmID,
+		"hostname":       hostname,
+		"local-hostname": hostname,
+	}
+
+	macs := map[string]interface{}{}
+	for i, iface := range machineConfig.FcNetworkInterfaces {
+		if iface.Result == nil {
+			continue
+		}
+		if i == 0 {
+			metaData["local-ipv4"] = iface.Result.IPAddr.IP.String()
+			metaData["mac"] = iface.Result.MacAddress
+		}
+		ifaceData := map[string]interface{}{
+			"device-number":          strconv.Itoa(i),
+			"mac":                    iface.Result.MacAddress,
+			"local-ipv4s":            iface.Result.IPAddr.IP.String(),
+			"subnet-ipv4-cidr-block": subnetOf(iface.Result.IPAddr),
+		}
+		if iface.Result.IPv6Addr != nil {
+			ifaceData["ipv6s"] = iface.Result.IPv6Addr.IP.String()
+			ifaceData["subnet-ipv6-cidr-blocks"] = subnetOf(*iface.Result.IPv6Addr)
+		}
+		macs[iface.Result.MacAddress] = ifaceData
+	}
+	metaData["network"] = map[string]interface{}{
+		"interfaces": map[string]interface{}{
+			"macs": macs,
+		},
+	}
+
+	tree := map[string]interface{}{
+		"meta-data": metaData,
+	}
+
+	if cloudInit := machineConfig.FcCloudInit; cloudInit.InMMDS() {
+		if len(cloudInit.SSHAuthorizedKeys) > 0 {
+			publicKeys := map[string]interface{}{}
+			for i, key := range cloudInit.SSHAuthorizedKeys {
+				publicKeys[strconv.Itoa(i)] = map[string]interface{}{
+					"openssh-key": key,
+				}
+			}
+			metaData["public-keys"] = publicKeys
+		}
+		if cloudInit.UserData != "" {
+			tree["user-data"] = cloudInit.UserData
+		}
+	}
+
+	return tree
+}
+
+func subnetOf(address net.IPNet) string {
+	return (&net.IPNet{IP: address.IP.Mask(address.Mask), Mask: address.Mask}).String()
+}
@@ -15,21 +15,27 @@ const (
 
 // NewMetadataExtractorHandler returns a firecracker handler which can be used to inject state into
 // a virtual machine file system prior to start.
-func NewMetadataExtractorHandler(logger hclog.Logger, metadata *configs.MetadataConfig) firecracker.Handler {
+// The contents are built when the handler runs, after the CNI networks are set up.
+func NewMetadataExtractorHandler(logger hclog.Logger, vmmID string, machineConfig *configs.MachineConfig) firecracker.Handler {
 	return firecracker.Handler{
 		Name: MetadataExtractorName,
 		Fn: func(ctx context.Context, m *firecracker.Machine) error {
 
-			serialized, err := metadata.Serialize()
-
-			if err != nil {
-				logger.Error("error while serializing metadata", "reason", err)
+			if err := m.SetMetadata(ctx, MMDSContents(vmmID, machineConfig)); err != nil {
+				logger.Error("error while setting metadata", "reason", err)
 				return err
 			}
 
-			m.SetMetadata(ctx, serialized)
-
 			return nil
 		},
 	}
 }
+
+// MMDSContents returns the MMDS contents of the VM: the user metadata and the generated keys,
+// the guest network configuration under the network key and the EC2 instance metadata under the latest key.
+func MMDSContents(vmmID string, machineConfig *configs.MachineConfig) map[string]interface{} {
+	contents := machineConfig.FcMetadata.Serialize()
+	contents["network"] = configs.NetworkMetadata(machineConfig.FcNetworkInterfaces)
+	contents["latest"] = imdsTree(vmmID, machineConfig)
+	return contents
+}
@@ -4,31 +4,44 @@ import (
 	"context"
 	"open-fire/configs"
 	"os"
-	"path/filepath"
 
 	"github.com/containernetworking/cni/libcni"
 	"github.com/hashicorp/go-hclog"
 	"github.com/pkg/errors"
 )
 
-// CleanupCNI removes the CNI interface from the network and cleans up the CNI cache directory.
-func CleanupCNI(logger hclog.Logger, cniConfig *configs.CNIConfig, vmmID, vethIfaceName, netName, netNS string) error {
-	logger.Info("cleaning up CNI network", "vmm-id", vmmID, "iface-name", vethIfaceName, "netns", netNS)
-	cniPlugin := libcni.NewCNIConfigWithCacheDir([]string{cniConfig.BinDir}, cniConfig.CacheDir, nil)
-	networkConfig, err := libcni.LoadConfList(cniConfig.ConfDir, netName)
-	if err != nil {
-		return errors.Wrap(err, "LoadConfList failed")
+// CleanupCNI removes the CNI interfaces from their networks and cleans up the CNI cache directory.
+// All interfaces are removed even if one of them fails, the first error is returned.
+// The egress policy rules of the VM are removed first, before the host interfaces they match go away.
+func CleanupCNI(logger hclog.Logger, cniConfig *configs.CNIConfig, vmmID, netNS string, interfaces []*configs.NetworkInterfaceConfig) error {
+	CleanupEgressPolicy(logger, vmmID)
+
+	cniPlugin := libcni.NewCNIConfigWithCacheDir([]string{cniConfig.BinDir}, cacheDir(cniConfig, vmmID), nil)
+
+	var firstErr error
+
+	for _, iface := range interfaces {
+		logger.Info("cleaning up CNI network", "vmm-id", vmmID, "network", iface.CNINetworkName, "iface-name", iface.IfName, "netns", netNS)
+		networkConfig, err := libcni.LoadConfList(cniConfig.ConfDir, iface.CNINetworkName)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrap(err, "LoadConfList failed")
+			}
+			continue
+		}
+		if err := cniPlugin.DelNetworkList(context.Background(), networkConfig, runtimeConfig(vmmID, netNS, iface)); err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrap(err, "DelNetworkList failed")
+			}
+		}
 	}
-	if err := cniPlugin.DelNetworkList(context.Background(), networkConfig, &libcni.RuntimeConf{
-		ContainerID: vmmID, // golang firecracker SDK uses the VMID, if VMID is set
-		NetNS:       netNS,
-		IfName:      vethIfaceName,
-	}); err != nil {
-		return errors.Wrap(err, "DelNetworkList failed")
+
+	if firstErr != nil {
+		return firstErr
 	}
 
 	// clean up the CNI interface directory:
-	ifaceCNIDir := filepath.Join(cniConfig.CacheDir, vmmID)
+	ifaceCNIDir := cacheDir(cniConfig, vmmID)
 	ifaceCNIDirStat, statErr := os.Stat(ifaceCNIDir)
 	if statErr != nil {
 		if os.IsNotExist(statErr) {
@@ -40,6 +53,7 @@ func CleanupCNI(logger hclog.Logger, cniConfig *configs.CNIConfig, vmmID, vethIf
 				"iface-cni-dir", ifaceCNIDir,
 				"reason", statErr)
 		}
+		return nil
 	}
 	if !ifaceCNIDirStat.IsDir() {
 		logger.Error("CNI directory path points to a file",
@@ -0,0 +1,240 @@
+package cni
+
+import (
+	"fmt"
+	"net"
+	"open-fire/configs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// egressChainPrefix prefixes the per VM iptables chain, chain names are limited to 28 characters.
+const egressChainPrefix = "OF-EG-"
+
+// egressHookChains are the built-in chains jumping to the VM chain: FORWARD for the traffic leaving the host,
+// INPUT for the traffic to the host itself, the gateway included.
+var egressHookChains = []string{"FORWARD", "INPUT"}
+
+// ipFamily is an IP family with the binary managing its netfilter rules.
+type ipFamily struct {
+	binary string
+	ipv6   bool
+}
+
+var ipFamilies = []ipFamily{
+	{binary: "iptables", ipv6: false},
+	{binary: "ip6tables", ipv6: true},
+}
+
+// SetupEgressPolicy installs the iptables rules enforcing the egress policy of the VM.
+// The traffic is matched on the host side interfaces of the VM, so it must be called after SetupCNI, and packets
+// from these interfaces with a source other than the VM IPs are dropped so a guest cannot spoof its way around the policy.
+// On dual-stack networks the IPv6 traffic is enforced with ip6tables, allowlist rules apply to the family of their CIDR.
+// Allowed packets return to the calling chain so the rules of the CNI plugins still apply.
+func SetupEgressPolicy(logger hclog.Logger, vmmID string, policy *configs.EgressPolicyConfig, interfaces []*configs.NetworkInterfaceConfig) error {
+	if !policy.Enforced() {
+		return nil
+	}
+
+	logger.Info("setting up egress policy", "vmm-id", vmmID, "mode", policy.Mode)
+
+	for _, iface := range interfaces {
+		if iface.Result == nil || iface.Result.HostIfName == "" {
+			return fmt.Errorf("no host interface for network %s in CNI result, the egress policy cannot be enforced", iface.CNINetworkName)
+		}
+	}
+	if err := enableBridgeNetfilter(); err != nil {
+		return err
+	}
+
+	for _, family := range ipFamilies {
+		if err := family.setupEgressChain(vmmID, policy, interfaces); err != nil {
+			CleanupEgressPolicy(logger, vmmID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CleanupEgressPolicy removes the iptables rules of the VM egress policy.
+// It is safe to call when no policy was installed, missing rules are ignored.
+func CleanupEgressPolicy(logger hclog.Logger, vmmID string) {
+	chain := egressChain(vmmID)
+
+	for _, family := range ipFamilies {
+		if err := family.run("-n", "-L", chain); err != nil {
+			// no chain, nothing to clean up
+			continue
+		}
+
+		logger.Info("cleaning up egress policy", "vmm-id", vmmID, "binary", family.binary)
+
+		for _, hookChain := range egressHookChains {
+			// a jump may have been inserted more than once by a failed setup
+			jumps, err := family.jumpsTo(hookChain, chain)
+			if err != nil {
+				logger.Error("failed listing egress jumps", "chain", hookChain, "reason", err)
+				continue
+			}
+			for _, jump := range jumps {
+				if err := family.run(append([]string{"-D", hookChain}, jump...)...); err != nil {
+					logger.Error("failed deleting egress jump", "chain", hookChain, "reason", err)
+				}
+			}
+		}
+		if err := family.run("-F", chain); err != nil {
+			logger.Error("failed flushing egress chain", "chain", chain, "reason", err)
+			continue
+		}
+		if err := family.run("-X", chain); err != nil {
+			logger.Error("failed deleting egress chain", "chain", chain, "reason", err)
+		}
+	}
+}
+
+func (family ipFamily) setupEgressChain(vmmID string, policy *configs.EgressPolicyConfig, interfaces []*configs.NetworkInterfaceConfig) error {
+	chain := egressChain(vmmID)
+	if err := family.run("-N", chain); err != nil {
+		return err
+	}
+
+	if family.ipv6 {
+		// neighbor discovery uses the link-local address of the guest
+		if err := family.run("-A", chain, "-s", "fe80::/10", "-p", "ipv6-icmp", "-j", "RETURN"); err != nil {
+			return err
+		}
+	}
+	// anything else must come from the addresses the VM was given, an interface without one in the family sends nothing
+	for _, iface := range interfaces {
+		spoofRule := hostInterfaceMatch(iface.Result.HostIfName)
+		for _, ip := range iface.Result.IPs() {
+			if family.matches(ip.String()) {
+				spoofRule = append(spoofRule, "!", "-s", ip.String())
+			}
+		}
+		if err := family.run(append([]string{"-A", chain}, append(spoofRule, "-j", "DROP")...)...); err != nil {
+			return err
+		}
+	}
+
+	rules := [][]string{
+		// replies to the connections initiated towards the VM, port mappings included
+		{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED"},
+	}
+	switch policy.Mode {
+	case configs.EgressPolicyDNSOnly:
+		rules = append(rules,
+			[]string{"-p", "udp", "--dport", "53"},
+			[]string{"-p", "tcp", "--dport", "53"})
+	case configs.EgressPolicyAllowlist:
+		for _, rule := range policy.Rules {
+			if family.matches(rule.CIDR) {
+				rules = append(rules, egressRuleArgs(rule))
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if err := family.run(append([]string{"-A", chain}, append(rule, "-j", "RETURN")...)...); err != nil {
+			return err
+		}
+	}
+	if err := family.run("-A", chain, "-j", "DROP"); err != nil {
+		return err
+	}
+
+	for _, iface := range interfaces {
+		for _, hookChain := range egressHookChains {
+			jump := append([]string{"-I", hookChain}, append(hostInterfaceMatch(iface.Result.HostIfName), "-j", chain)...)
+			if err := family.run(jump...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jumpsTo returns the match arguments of the rules of the hook chain jumping to the chain.
+func (family ipFamily) jumpsTo(hookChain, chain string) ([][]string, error) {
+	output, err := exec.Command(family.binary, "-w", "-S", hookChain).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s -S %s failed: %s", family.binary, hookChain, err)
+	}
+	jumps := [][]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		// -A <hook chain> <match...> -j <chain>
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "-A" || fields[len(fields)-2] != "-j" || fields[len(fields)-1] != chain {
+			continue
+		}
+		jumps = append(jumps, fields[2:])
+	}
+	return jumps, nil
+}
+
+// matches returns true if the address or network belongs to the family.
+func (family ipFamily) matches(address string) bool {
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		ip = net.ParseIP(address)
+	}
+	return ip != nil && (ip.To4() == nil) == family.ipv6
+}
+
+// run runs the binary, waiting for the xtables lock held by the CNI plugins.
+func (family ipFamily) run(args ...string) error {
+	output, err := exec.Command(family.binary, append([]string{"-w"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %s: %s", family.binary, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func egressChain(vmmID string) string {
+	return egressChainPrefix + vmmID
+}
+
+func egressRuleArgs(rule *configs.EgressRuleConfig) []string {
+	args := []string{"-d", rule.CIDR}
+	if rule.Protocol != "" {
+		args = append(args, "-p", rule.Protocol)
+	}
+	if len(rule.Ports) > 0 {
+		ports := []string{}
+		for _, port := range rule.Ports {
+			ports = append(ports, strconv.Itoa(port))
+		}
+		args = append(args, "-m", "multiport", "--dports", strings.Join(ports, ","))
+	}
+	return args
+}
+
+// hostInterfaceMatch returns the iptables match of the packets sent by the VM through its host interface.
+// A veth attached to a bridge is matched as the bridge port the packets came in from, which needs br_netfilter,
+// as the packets reach iptables on the bridge itself.
+func hostInterfaceMatch(name string) []string {
+	if _, err := os.Stat(filepath.Join("/sys/class/net", name, "brport")); err == nil {
+		return []string{"-m", "physdev", "--physdev-in", name}
+	}
+	return []string{"-i", name}
+}
+
+// enableBridgeNetfilter loads br_netfilter and lets the bridged packets through iptables and ip6tables,
+// without it the bridge ports of the packets are unknown to iptables.
+func enableBridgeNetfilter() error {
+	if output, err := exec.Command("modprobe", "br_netfilter").CombinedOutput(); err != nil {
+		return fmt.Errorf("modprobe br_netfilter failed: %s: %s", err, strings.TrimSpace(string(output)))
+	}
+	for _, binary := range []string{"iptables", "ip6tables"} {
+		if err := os.WriteFile("/proc/sys/net/bridge/bridge-nf-call-"+binary, []byte("1"), 0644); err != nil {
+			return fmt.Errorf("failed enabling bridge-nf-call-%s: %w", binary, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cni
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"open-fire/configs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+var (
+	// ErrConfListExists is returned when writing a network whose conflist file already exists.
+	ErrConfListExists = errors.New("conflist already exists")
+)
+
+// confExtensions are the configuration file extensions read by libcni.
+var confExtensions = []string{".conf", ".conflist", ".json"}
+
+// Network is a CNI network defined in the configuration directory.
+type Network struct {
+	Name       string
+	File       string
+	Bridge     string
+	Subnets    []*net.IPNet
+	Masquerade bool
+	Plugins    []string
+}
+
+// pluginConf holds the plugin settings describing the network, as used by the bridge plugin and the host-local IPAM.
+type pluginConf struct {
+	Type   string `json:"type"`
+	Bridge string `json:"bridge"`
+	IPMasq bool   `json:"ipMasq"`
+	IPAM   struct {
+		Subnet string `json:"subnet"`
+		Ranges [][]struct {
+			Subnet string `json:"subnet"`
+		} `json:"ranges"`
+	} `json:"ipam"`
+}
+
+// ListNetworks returns the networks defined in the configuration directory, ordered by name.
+// Like libcni, files which cannot be parsed are skipped.
+func ListNetworks(confDir string) ([]*Network, error) {
+	files, err := libcni.ConfFiles(confDir, confExtensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading CNI configuration directory %s: %w", confDir, err)
+	}
+
+	result := []*Network{}
+	for _, file := range files {
+		networkConfig, err := confListFromFile(file)
+		if err != nil {
+			continue
+		}
+		result = append(result, networkFrom(networkConfig, file))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// WriteNetwork writes the conflist of the network to <confDir>/<name>.conflist and returns the file path.
+// The file is written next to its final location and linked, so a half written file is never loaded
+// and an existing file, possibly one libcni failed to parse, is never replaced.
+func WriteNetwork(confDir string, network *configs.CNINetworkConfig) (string, error) {
+	confList, err := network.ConfList()
+	if err != nil {
+		return "", fmt.Errorf("failed rendering the conflist: %w", err)
+	}
+	if err := os.MkdirAll(confDir, 0755); err != nil {
+		return "", fmt.Errorf("failed creating CNI configuration directory: %w", err)
+	}
+
+	file := filepath.Join(confDir, network.Name+".conflist")
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, confList, 0644); err != nil {
+		return "", fmt.Errorf("failed writing the conflist: %w", err)
+	}
+	defer os.Remove(tmpFile)
+	if err := os.Link(tmpFile, file); err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrConfListExists, file)
+		}
+		return "", fmt.Errorf("failed writing the conflist: %w", err)
+	}
+	return file, nil
+}
+
+func confListFromFile(file string) (*libcni.NetworkConfigList, error) {
+	if filepath.Ext(file) == ".conflist" {
+		return libcni.ConfListFromFile(file)
+	}
+	conf, err := libcni.ConfFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return libcni.ConfListFromConf(conf)
+}
+
+func networkFrom(networkConfig *libcni.NetworkConfigList, file string) *Network {
+	network := &Network{
+		Name:    networkConfig.Name,
+		File:    file,
+		Subnets: []*net.IPNet{},
+		Plugins: []string{},
+	}
+	for _, plugin := range networkConfig.Plugins {
+		network.Plugins = append(network.Plugins, plugin.Network.Type)
+
+		var conf pluginConf
+		if err := json.Unmarshal(plugin.Bytes, &conf); err != nil {
+			continue
+		}
+		if conf.Bridge != "" && network.Bridge == "" {
+			network.Bridge = conf.Bridge
+			network.Masquerade = conf.IPMasq
+		}
+		subnets := []string{conf.IPAM.Subnet}
+		for _, rangeSet := range conf.IPAM.Ranges {
+			for _, ipRange := range rangeSet {
+				subnets = append(subnets, ipRange.Subnet)
+			}
+		}
+		for _, subnet := range subnets {
+			if _, ipNet, err := net.ParseCIDR(subnet); err == nil {
+				network.Subnets = append(network.Subnets, ipNet)
+			}
+		}
+	}
+	return network
+}
@@ -0,0 +1,317 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"open-fire/configs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/containernetworking/cni/libcni"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const portMappingsCapability = "portMappings"
+
+// hostLocalDataDir is the default directory of the leases of the host-local IPAM.
+const hostLocalDataDir = "/var/lib/cni/networks"
+
+var (
+	// ErrIPAlreadyLeased is returned when the requested static IP is already leased by the IPAM.
+	ErrIPAlreadyLeased = stderrors.New("ip address already leased")
+)
+
+// SetupCNI adds every network interface to its CNI network and stores the guest configuration in the interface result.
+// The firecracker SDK can only handle a single CNI interface, so the networks are set up here
+// and handed over to the SDK as static interfaces.
+// On error, the interfaces which were already added are removed.
+func SetupCNI(ctx context.Context, logger hclog.Logger, cniConfig *configs.CNIConfig, vmmID, netNS string, interfaces []*configs.NetworkInterfaceConfig) error {
+	if err := ensureNetNS(netNS); err != nil {
+		return errors.Wrap(err, "failed to initialize netns")
+	}
+
+	cniPlugin := libcni.NewCNIConfigWithCacheDir([]string{cniConfig.BinDir}, cacheDir(cniConfig, vmmID), nil)
+
+	added := []*configs.NetworkInterfaceConfig{}
+
+	for _, iface := range interfaces {
+		logger.Info("setting up CNI network", "vmm-id", vmmID, "network", iface.CNINetworkName, "iface-name", iface.IfName)
+
+		networkConfig, err := libcni.LoadConfList(cniConfig.ConfDir, iface.CNINetworkName)
+		if err != nil {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return errors.Wrapf(err, "LoadConfList failed for network %s", iface.CNINetworkName)
+		}
+
+		if len(iface.PortMappings) > 0 && !hasCapability(networkConfig, portMappingsCapability) {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return fmt.Errorf("network %s does not support port mappings, add the portmap plugin with the portMappings capability", iface.CNINetworkName)
+		}
+
+		runtimeConf := runtimeConfig(vmmID, netNS, iface)
+
+		// remove leftovers of a previous run, well-behaved plugins treat this as a no-op
+		if err := cniPlugin.DelNetworkList(ctx, networkConfig, runtimeConf); err != nil {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return errors.Wrapf(err, "failed to delete pre-existing CNI network %s", iface.CNINetworkName)
+		}
+
+		// the IPs of this server's VMs are reserved at start, the leases also cover the VMs of other processes
+		if iface.IPAddress != "" && hostLocalLeased(networkConfig, iface.IPAddress) {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return fmt.Errorf("%w: %s on network %s", ErrIPAlreadyLeased, iface.IPAddress, iface.CNINetworkName)
+		}
+
+		cniResult, err := cniPlugin.AddNetworkList(ctx, networkConfig, runtimeConf)
+		// AddNetworkList may leave intermediate resources behind on failure
+		added = append(added, iface)
+		if err != nil {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return errors.Wrapf(err, "AddNetworkList failed for network %s", iface.CNINetworkName)
+		}
+
+		result, err := current.NewResultFromResult(cniResult)
+		if err != nil {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return errors.Wrap(err, "failed to parse CNI result")
+		}
+
+		ifaceResult, err := interfaceResultFrom(result, vmmID)
+		if err != nil {
+			CleanupCNI(logger, cniConfig, vmmID, netNS, added)
+			return errors.Wrapf(err, "failed to parse VM network configuration of network %s, ensure the network uses the tc-redirect-tap plugin", iface.CNINetworkName)
+		}
+		iface.Result = ifaceResult
+	}
+
+	return nil
+}
+
+// interfaceResultFrom finds the VM pseudo interface, which has the VMM ID as the sandbox,
+// and the tap device with the same name in the network namespace.
+func interfaceResultFrom(result *current.Result, vmmID string) (*configs.NetworkInterfaceResult, error) {
+	vmIfaceIndex := -1
+	for i, iface := range result.Interfaces {
+		if iface.Sandbox == vmmID {
+			vmIfaceIndex = i
+			break
+		}
+	}
+	if vmIfaceIndex == -1 {
+		return nil, fmt.Errorf("no interface with sandbox %s in CNI result", vmmID)
+	}
+	vmIface := result.Interfaces[vmIfaceIndex]
+
+	var tapIface *current.Interface
+	for _, iface := range result.Interfaces {
+		if iface.Name == vmIface.Name && iface.Sandbox != vmmID {
+			tapIface = iface
+			break
+		}
+	}
+	if tapIface == nil {
+		return nil, fmt.Errorf("no tap device named %s in CNI result", vmIface.Name)
+	}
+
+	// dual-stack networks give the interface one IP of each family
+	var vmIP, vmIPv6 *current.IPConfig
+	for _, ip := range result.IPs {
+		if ip.Interface == nil || *ip.Interface != vmIfaceIndex {
+			continue
+		}
+		if ip.Address.IP.To4() != nil {
+			if vmIP != nil {
+				return nil, fmt.Errorf("expected to find 1 IPv4 address for vm interface %s", vmIface.Name)
+			}
+			vmIP = ip
+		} else {
+			if vmIPv6 != nil {
+				return nil, fmt.Errorf("expected to find at most 1 IPv6 address for vm interface %s", vmIface.Name)
+			}
+			vmIPv6 = ip
+		}
+	}
+	// the guest IP is configured with the ip= kernel argument, which only supports IPv4
+	if vmIP == nil {
+		return nil, fmt.Errorf("no IPv4 address for vm interface %s in CNI result, IPv6 only networks are not supported", vmIface.Name)
+	}
+
+	ifaceResult := &configs.NetworkInterfaceResult{
+		TapName:     tapIface.Name,
+		HostIfName:  hostInterfaceName(result),
+		MacAddress:  vmIface.Mac,
+		IPAddr:      vmIP.Address,
+		Gateway:     vmIP.Gateway,
+		Nameservers: result.DNS.Nameservers,
+	}
+	if vmIPv6 != nil {
+		ipv6Addr := vmIPv6.Address
+		ifaceResult.IPv6Addr = &ipv6Addr
+		ifaceResult.IPv6Gateway = vmIPv6.Gateway
+	}
+	return ifaceResult, nil
+}
+
+// hostInterfaceName returns the host side of the interface, the veth which is not in a sandbox, or an empty string
+// if the plugins did not report one. The bridge the veth is attached to is not in a sandbox either and is skipped.
+func hostInterfaceName(result *current.Result) string {
+	for _, iface := range result.Interfaces {
+		if iface.Sandbox != "" || isBridge(iface.Name) {
+			continue
+		}
+		return iface.Name
+	}
+	return ""
+}
+
+func isBridge(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name, "bridge"))
+	return err == nil
+}
+
+// hostLocalLeased returns true if the host-local IPAM of the network has a lease on the IP, a file named after
+// the IP in its data directory. The leases of the VM itself were released by the DelNetworkList preceding the add.
+func hostLocalLeased(networkConfig *libcni.NetworkConfigList, ipAddress string) bool {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+	for _, plugin := range networkConfig.Plugins {
+		var conf struct {
+			IPAM struct {
+				Type    string `json:"type"`
+				DataDir string `json:"dataDir"`
+			} `json:"ipam"`
+		}
+		if err := json.Unmarshal(plugin.Bytes, &conf); err != nil || conf.IPAM.Type != "host-local" {
+			continue
+		}
+		dataDir := conf.IPAM.DataDir
+		if dataDir == "" {
+			dataDir = hostLocalDataDir
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, networkConfig.Name, ip.String())); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func runtimeConfig(vmmID, netNS string, iface *configs.NetworkInterfaceConfig) *libcni.RuntimeConf {
+	return &libcni.RuntimeConf{
+		ContainerID: vmmID, // golang firecracker SDK uses the VMID, if VMID is set
+		NetNS:       netNS,
+		IfName:      iface.IfName,
+		Args:        cniArgs(iface),
+		CapabilityArgs: map[string]interface{}{
+			portMappingsCapability: portMappings(iface),
+		},
+	}
+}
+
+// portMapEntry is the runtime configuration expected by the portmap plugin.
+type portMapEntry struct {
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"hostIP,omitempty"`
+}
+
+func portMappings(iface *configs.NetworkInterfaceConfig) []portMapEntry {
+	entries := []portMapEntry{}
+	for _, portMapping := range iface.PortMappings {
+		entries = append(entries, portMapEntry{
+			HostPort:      portMapping.HostPort,
+			ContainerPort: portMapping.GuestPort,
+			Protocol:      portMapping.Protocol,
+			HostIP:        portMapping.HostIP,
+		})
+	}
+	return entries
+}
+
+func hasCapability(networkConfig *libcni.NetworkConfigList, capability string) bool {
+	for _, plugin := range networkConfig.Plugins {
+		if plugin.Network.Capabilities[capability] {
+			return true
+		}
+	}
+	return false
+}
+
+// cniArgs returns the CNI_ARGS requesting the static IP from the host-local IPAM and the MAC from the bridge plugin.
+// Every plugin of the chain receives the args, IgnoreUnknown keeps the ones which don't know them from failing.
+func cniArgs(iface *configs.NetworkInterfaceConfig) [][2]string {
+	args := [][2]string{}
+	if iface.IPAddress != "" {
+		args = append(args, [2]string{"IP", iface.IPAddress})
+	}
+	if iface.MacAddress != "" {
+		args = append(args, [2]string{"MAC", iface.MacAddress})
+	}
+	if len(args) > 0 {
+		args = append([][2]string{{"IgnoreUnknown", "1"}}, args...)
+	}
+	return args
+}
+
+// the same cache directory the firecracker SDK would use
+func cacheDir(cniConfig *configs.CNIConfig, vmmID string) string {
+	return filepath.Join(cniConfig.CacheDir, vmmID)
+}
+
+// ensureNetNS creates and mounts a network namespace at the path, if it doesn't exist yet.
+func ensureNetNS(netNS string) error {
+	err := ns.IsNSorErr(netNS)
+	switch err.(type) {
+	case nil:
+		return nil
+	case ns.NSPathNotNSErr:
+		return fmt.Errorf("path %q does not appear to be a mounted netns: %w", netNS, err)
+	case ns.NSPathNotExistErr:
+	default:
+		return fmt.Errorf("failure checking if %q is a mounted netns: %w", netNS, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(netNS), 0600); err != nil {
+		return fmt.Errorf("failed to create netns parent dir: %w", err)
+	}
+
+	// a file must exist at the path for the bind mount to succeed
+	fd, err := os.OpenFile(netNS, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open new netns path at %q: %w", netNS, err)
+	}
+	fd.Close()
+
+	doneCh := make(chan error)
+	go func() {
+		defer close(doneCh)
+		// the thread is never unlocked, it is discarded when the goroutine exits
+		// so the new namespace does not leak to other goroutines
+		runtime.LockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			doneCh <- fmt.Errorf("failed to unshare netns: %w", err)
+			return
+		}
+
+		if err := unix.Mount("/proc/thread-self/ns/net", netNS, "none", unix.MS_BIND, "none"); err != nil {
+			doneCh <- fmt.Errorf("failed to mount netns at path %q: %w", netNS, err)
+		}
+	}()
+
+	if err := <-doneCh; err != nil {
+		os.Remove(netNS)
+		return err
+	}
+	return nil
+}
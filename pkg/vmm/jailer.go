@@ -0,0 +1,73 @@
+package vmm
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+
+	"open-fire/configs"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+)
+
+// jailerSocketPath is the Firecracker API socket in the jail, the default of the SDK.
+const jailerSocketPath = "/run/firecracker.socket"
+
+// jailerCommand returns the jailer command the SDK would run for the configuration, with the cgroup settings and the
+// resource limits it does not support. The cpuset.cpus of the NUMA node is replaced by the one of the limits, if set.
+func jailerCommand(ctx context.Context, fcConfig firecracker.Config, limits *configs.JailerLimitsConfig) *exec.Cmd {
+	jailerCfg := fcConfig.JailerCfg
+
+	fcArgs := []string{}
+	if !fcConfig.Seccomp.Enabled {
+		fcArgs = append(fcArgs, "--no-seccomp")
+	} else if fcConfig.Seccomp.Filter != "" {
+		fcArgs = append(fcArgs, "--seccomp-filter", fcConfig.Seccomp.Filter)
+	}
+	fcArgs = append(fcArgs, "--api-sock", jailerSocketPath)
+
+	builder := firecracker.NewJailerCommandBuilder().
+		WithBin(jailerCfg.JailerBinary).
+		WithID(jailerCfg.ID).
+		WithUID(*jailerCfg.UID).
+		WithGID(*jailerCfg.GID).
+		WithNumaNode(*jailerCfg.NumaNode).
+		WithExecFile(jailerCfg.ExecFile).
+		WithChrootBaseDir(jailerCfg.ChrootBaseDir).
+		WithDaemonize(jailerCfg.Daemonize).
+		WithCgroupVersion(jailerCfg.CgroupVersion).
+		WithFirecrackerArgs(fcArgs...)
+	if fcConfig.NetNS != "" {
+		builder = builder.WithNetNS(fcConfig.NetNS)
+	}
+
+	limitArgs := []string{}
+	for _, cgroup := range limits.CgroupArgs() {
+		limitArgs = append(limitArgs, "--cgroup", cgroup)
+	}
+	for _, resourceLimit := range limits.ResourceLimitArgs() {
+		limitArgs = append(limitArgs, "--resource-limit", resourceLimit)
+	}
+
+	args := []string{}
+	builderArgs := builder.Args()
+	for i := 0; i < len(builderArgs); i++ {
+		if builderArgs[i] == "--" {
+			args = append(args, limitArgs...)
+			args = append(args, builderArgs[i:]...)
+			break
+		}
+		if builderArgs[i] == "--cgroup" && i+1 < len(builderArgs) &&
+			strings.HasPrefix(builderArgs[i+1], "cpuset.cpus=") && limits.CpusetCpus != "" {
+			i++
+			continue
+		}
+		args = append(args, builderArgs[i])
+	}
+
+	cmd := exec.CommandContext(ctx, builder.Bin(), args...)
+	cmd.Stdin = jailerCfg.Stdin
+	cmd.Stdout = jailerCfg.Stdout
+	cmd.Stderr = jailerCfg.Stderr
+	return cmd
+}
@@ -34,9 +34,8 @@ type defaultStartedMachine struct {
 	jailingFcConfig *configs.JailingFirecrackerConfig
 	machineConfig   *configs.MachineConfig
 
-	logger        hclog.Logger
-	machine       *firecracker.Machine
-	vethIfaceName string
+	logger  hclog.Logger
+	machine *firecracker.Machine
 
 	wasStopped bool
 }
@@ -116,9 +115,8 @@ func (m *defaultStartedMachine) Wait(ctx context.Context) {
 func (m *defaultStartedMachine) cleanupCNINetwork() error {
 	return cni.CleanupCNI(m.logger, m.cniConfig,
 		m.machine.Cfg.VMID,
-		m.vethIfaceName,
-		m.machineConfig.CNINetworkName,
-		m.machine.Cfg.NetNS)
+		m.machine.Cfg.NetNS,
+		m.machineConfig.FcNetworkInterfaces)
 }
 
 func (m *defaultStartedMachine) RunningMachine() *firecracker.Machine {
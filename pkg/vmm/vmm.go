@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"open-fire/configs"
 	"open-fire/pkg/vmm/chroot"
+	"open-fire/pkg/vmm/cni"
 
 	"github.com/firecracker-microvm/firecracker-go-sdk"
 	"github.com/hashicorp/go-hclog"
@@ -28,15 +29,22 @@ type Provider interface {
 	Start(context.Context) (StartedMachine, error)
 
 	WithHandlersAdapter(firecracker.HandlersAdapter) Provider
+	// WithNetworkReadyHook sets a function called once the CNI networks are set up,
+	// before the machine configuration is built from the interface results.
+	WithNetworkReadyHook(NetworkReadyHook) Provider
 }
 
+// NetworkReadyHook can adjust the interface results before they are handed over to the VMM.
+type NetworkReadyHook func([]*configs.NetworkInterfaceConfig) error
+
 type defaultProvider struct {
 	cniConfig       *configs.CNIConfig
 	jailingFcConfig *configs.JailingFirecrackerConfig
 	machineConfig   *configs.MachineConfig
 
-	handlersAdapter firecracker.HandlersAdapter
-	logger          hclog.Logger
+	handlersAdapter  firecracker.HandlersAdapter
+	networkReadyHook NetworkReadyHook
+	logger           hclog.Logger
 }
 
 // NewDefaultProvider creates a default provider.
@@ -68,19 +76,49 @@ func (p *defaultProvider) Start(ctx context.Context) (StartedMachine, error) {
 			WithClient(firecracker.NewClient(machineChroot.SocketPath(), vmmLoggerEntry, true)))
 	}
 
+	if err := cni.SetupCNI(ctx, p.logger, p.cniConfig, p.jailingFcConfig.VMMID(), p.jailingFcConfig.NetNS, p.machineConfig.FcNetworkInterfaces); err != nil {
+		return nil, fmt.Errorf("failed setting up CNI networks: %w", err)
+	}
+
+	cleanupCNI := func() {
+		if err := cni.CleanupCNI(p.logger, p.cniConfig, p.jailingFcConfig.VMMID(), p.jailingFcConfig.NetNS, p.machineConfig.FcNetworkInterfaces); err != nil {
+			p.logger.Error("failed cleaning up CNI networks", "reason", err)
+		}
+	}
+
+	if err := cni.SetupEgressPolicy(p.logger, p.jailingFcConfig.VMMID(), p.machineConfig.FcEgressPolicy, p.machineConfig.FcNetworkInterfaces); err != nil {
+		cleanupCNI()
+		return nil, fmt.Errorf("failed setting up egress policy: %w", err)
+	}
+
+	if p.networkReadyHook != nil {
+		if err := p.networkReadyHook(p.machineConfig.FcNetworkInterfaces); err != nil {
+			cleanupCNI()
+			return nil, fmt.Errorf("network ready hook failed: %w", err)
+		}
+	}
+
 	fcConfig, err := configs.NewFcConfigProvider(p.jailingFcConfig, p.machineConfig).
 		WithHandlersAdapter(p.handlersAdapter).
 		ToSDKConfig()
 
 	if err != nil {
+		cleanupCNI()
 		return &defaultStartedMachine{}, err
 	}
 
+	if p.machineConfig.FcJailerLimits != nil {
+		machineOpts = append(machineOpts, firecracker.
+			WithProcessRunner(jailerCommand(ctx, fcConfig, p.machineConfig.FcJailerLimits)))
+	}
+
 	m, err := firecracker.NewMachine(ctx, fcConfig, machineOpts...)
 	if err != nil {
+		cleanupCNI()
 		return nil, fmt.Errorf("failed creating machine: %s", err)
 	}
 	if err := m.Start(ctx); err != nil {
+		cleanupCNI()
 		return nil, fmt.Errorf("failed to start machine: %v", err)
 	}
 
@@ -97,3 +135,8 @@ func (p *defaultProvider) WithHandlersAdapter(input firecracker.HandlersAdapter)
 	p.handlersAdapter = input
 	return p
 }
+
+func (p *defaultProvider) WithNetworkReadyHook(input NetworkReadyHook) Provider {
+	p.networkReadyHook = input
+	return p
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/managers"
+)
+
+func updateNetworkInterfaceRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, params []string) {
+
+	var req requests.UpdateNetworkInterfaceRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	if req.InRateLimiter == nil && req.OutRateLimiter == nil {
+		writeErrorResponse(w, 422, "at least one of inRateLimiter or outRateLimiter is required")
+		return
+	}
+
+	inRateLimiter := configs.NewRateLimiterConfigFromRequest(req.InRateLimiter)
+	if inRateLimiter != nil {
+		if err := inRateLimiter.Validate(); err != nil {
+			writeErrorResponse(w, 422, fmt.Sprintf("invalid inRateLimiter: %s", err))
+			return
+		}
+	}
+
+	outRateLimiter := configs.NewRateLimiterConfigFromRequest(req.OutRateLimiter)
+	if outRateLimiter != nil {
+		if err := outRateLimiter.Validate(); err != nil {
+			writeErrorResponse(w, 422, fmt.Sprintf("invalid outRateLimiter: %s", err))
+			return
+		}
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.UpdateNetworkInterfaceRateLimiters(vmID, params[0], inRateLimiter, outRateLimiter); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func updateDriveRequestHandler(w http.ResponseWriter, r *http.Request, vmID string, params []string) {
+
+	var req requests.UpdateDriveRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	if req.RateLimiter == nil && req.PathOnHost == "" {
+		writeErrorResponse(w, 422, "at least one of pathOnHost or rateLimiter is required")
+		return
+	}
+
+	rateLimiter := configs.NewRateLimiterConfigFromRequest(req.RateLimiter)
+	if rateLimiter != nil {
+		if err := rateLimiter.Validate(); err != nil {
+			writeErrorResponse(w, 422, fmt.Sprintf("invalid rateLimiter: %s", err))
+			return
+		}
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if req.PathOnHost != "" {
+		if err := fcManager.UpdateDrivePath(vmID, params[0], req.PathOnHost); err != nil {
+			writeErrorResponse(w, managerErrorStatus(err), err.Error())
+			return
+		}
+	}
+
+	if rateLimiter != nil {
+		if err := fcManager.UpdateDriveRateLimiter(vmID, params[0], rateLimiter); err != nil {
+			writeErrorResponse(w, managerErrorStatus(err), err.Error())
+			return
+		}
+	}
+
+	w.WriteHeader(204)
+}
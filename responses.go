@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"open-fire/managers"
+)
+
+// writeErrorResponse writes an ErrorResponse with the given status code.
+func writeErrorResponse(w http.ResponseWriter, statusCode int, errorMsg string) {
+	response := buildCreateVMError(errorMsg)
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
+
+// writeJSONResponse marshals the value and writes it with the given status code.
+func writeJSONResponse(w http.ResponseWriter, statusCode int, value interface{}) {
+	response, err := json.Marshal(value)
+	if err != nil {
+		writeErrorResponse(w, 500, "failed to marshal response json: "+err.Error())
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
+
+// readJSONBody reads the request body into the value.
+func readJSONBody(r *http.Request, value interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errors.New("failed to read body " + err.Error())
+	}
+	if err := json.Unmarshal(body, value); err != nil {
+		rootLogger.Error(err.Error())
+		return errors.New("failed to read json body")
+	}
+	return nil
+}
+
+// managerErrorStatus maps an error returned by the manager to an HTTP status code.
+func managerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, managers.ErrInvalidMetadata), errors.Is(err, managers.ErrInvalidDriveUpdate),
+		errors.Is(err, managers.ErrInvalidDiskPath), errors.Is(err, managers.ErrInvalidCatalogEntry),
+		errors.Is(err, managers.ErrInvalidVolume), errors.Is(err, managers.ErrInvalidRootfsSize),
+		errors.Is(err, managers.ErrInvalidCPUTemplate), errors.Is(err, managers.ErrInvalidSeccompFilter),
+		errors.Is(err, managers.ErrInvalidHostIP):
+		return 422
+	case errors.Is(err, managers.ErrVMNotFound), errors.Is(err, managers.ErrDeviceNotFound),
+		errors.Is(err, managers.ErrNetworkNotFound), errors.Is(err, managers.ErrDiskNotFound), errors.Is(err, managers.ErrDiskFileNotFound),
+		errors.Is(err, managers.ErrCatalogEntryNotFound), errors.Is(err, managers.ErrVolumeNotFound),
+		errors.Is(err, managers.ErrCPUTemplateNotFound), errors.Is(err, managers.ErrSeccompFilterNotFound):
+		return 404
+	case errors.Is(err, managers.ErrBalloonNotConfigured), errors.Is(err, managers.ErrIPConflict),
+		errors.Is(err, managers.ErrPortConflict), errors.Is(err, managers.ErrNameConflict), errors.Is(err, managers.ErrNetworkExists),
+		errors.Is(err, managers.ErrSubnetOverlap), errors.Is(err, managers.ErrNetworkInUse), errors.Is(err, managers.ErrDiskInUse),
+		errors.Is(err, managers.ErrDiskNotMounted), errors.Is(err, managers.ErrCatalogEntryExists), errors.Is(err, managers.ErrCatalogEntryInUse),
+		errors.Is(err, managers.ErrVolumeExists), errors.Is(err, managers.ErrVolumeInUse),
+		errors.Is(err, managers.ErrCPUTemplateInUse), errors.Is(err, managers.ErrSeccompFilterInUse):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"strings"
+)
+
+// seccompFilterMaxBytes is the maximum size of an uploaded seccomp filter definition.
+const seccompFilterMaxBytes = 1 << 20
+
+// seccompFiltersRequestHandler dispatches the requests made to /v1/seccomp-filters and /v1/seccomp-filters/{name}.
+func seccompFiltersRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/seccomp-filters"), "/")
+
+	switch {
+	case strings.Contains(name, "/"):
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	case name == "" && r.Method == http.MethodGet:
+		listSeccompFiltersRequestHandler(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		getSeccompFilterRequestHandler(w, r, name)
+	case name != "" && r.Method == http.MethodPut:
+		putSeccompFilterRequestHandler(w, r, name)
+	case name != "" && r.Method == http.MethodDelete:
+		deleteSeccompFilterRequestHandler(w, r, name)
+	default:
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+	}
+}
+
+func listSeccompFiltersRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	filters, err := fcManager.ListSeccompFilters()
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListSeccompFiltersResponse{
+		Builtin: []string{configs.SeccompFilterDefault, configs.SeccompFilterNone},
+		Custom:  []response.SeccompFilterResponse{},
+		Default: fcManager.SeccompFiltersDefault(),
+	}
+	for _, filter := range filters {
+		resp.Custom = append(resp.Custom, seccompFilterResponse(filter))
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+// getSeccompFilterRequestHandler writes the JSON definition of the custom seccomp filter as is.
+func getSeccompFilterRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	_, contents, err := fcManager.GetSeccompFilter(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(contents)
+}
+
+// putSeccompFilterRequestHandler creates or replaces a custom seccomp filter with the JSON body, in the seccompiler format.
+func putSeccompFilterRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	contents, err := io.ReadAll(http.MaxBytesReader(w, r.Body, seccompFilterMaxBytes))
+	if err != nil {
+		writeErrorResponse(w, 422, "failed to read body "+err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	filter, created, err := fcManager.PutSeccompFilter(name, contents)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	statusCode := 200
+	if created {
+		statusCode = 201
+	}
+	resp := seccompFilterResponse(filter)
+	writeJSONResponse(w, statusCode, &resp)
+}
+
+func deleteSeccompFilterRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.DeleteSeccompFilter(name); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func seccompFilterResponse(filter *managers.SeccompFilterInfo) response.SeccompFilterResponse {
+	return response.SeccompFilterResponse{
+		Name:    filter.Name,
+		Path:    filter.Path,
+		BPFPath: filter.BPFPath,
+		UsedBy:  filter.UsedBy,
+	}
+}
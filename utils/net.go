@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"net"
+	"strconv"
+)
+
+// CheckPortAvailable returns an error if something on the host listens on the port for the protocol, tcp or udp,
+// or if the host IP is not an address of the host, the error then wraps syscall.EADDRNOTAVAIL.
+// With an empty host IP, the port is checked on all the IPv4 and IPv6 addresses.
+func CheckPortAvailable(protocol, hostIP string, port int) error {
+	address := net.JoinHostPort(hostIP, strconv.Itoa(port))
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
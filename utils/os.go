@@ -1,12 +1,14 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 // CheckIfExistsAndIsDirectory checks is a path points at a directory.
@@ -61,6 +63,31 @@ func CopyFile(source, dest string, bufferSize int) error {
 	return nil
 }
 
+// CloneFile uses cp to create a copy-on-write clone of a file, a reflink where the filesystem supports it
+// and a sparse copy otherwise.
+func CloneFile(source, target string) error {
+	exitCode, cmdErr := RunCommand("cp", "--reflink=auto", "--sparse=always", "--", source, target)
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
+// CopyDirContents sudo copies the contents of a directory into another one, preserving ownership, modes and links.
+func CopyDirContents(source, target string) error {
+	exitCode, cmdErr := RunShellCommandSudo(fmt.Sprintf("cp -a %s/. %s/", source, target))
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
 // CreateRootFSFile uses dd to create a rootfs file of given size at a given path.
 func CreateRootFSFile(path string, size int) error {
 	exitCode, cmdErr := RunShellCommandNoSudo(fmt.Sprintf("dd if=/dev/zero of=%s bs=1M count=%d", path, size))
@@ -93,9 +120,82 @@ func MkfsExt4(path string) error {
 	return nil
 }
 
-// Mount sudo mounts a rootfs file at a location.
-func Mount(file, dir string) error {
-	exitCode, cmdErr := RunShellCommandSudo(fmt.Sprintf("mount %s %s", file, dir))
+// ResizeExt4 checks the EXT4 file system of a given file then grows it to the size of the file.
+func ResizeExt4(path string) error {
+	if err := CheckExt4(path); err != nil {
+		return err
+	}
+	return GrowExt4(path)
+}
+
+// CheckExt4 uses e2fsck to check the EXT4 file system of a given file, fixing what can be fixed safely.
+func CheckExt4(path string) error {
+	_, cmdErr := RunCommand("e2fsck", "-f", "-p", "--", path)
+	if cmdErr != nil {
+		// exit code 1 means errors were corrected, any other failure, starting the command included, is an error
+		if exitError, ok := cmdErr.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return nil
+		}
+		return cmdErr
+	}
+	return nil
+}
+
+// GrowExt4 uses resize2fs to grow the checked EXT4 file system of a given file to the size of the file.
+func GrowExt4(path string) error {
+	exitCode, cmdErr := RunCommand("resize2fs", "--", path)
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
+// MkfsVfat uses mkfs.vfat to create a FAT file system with a label in a given file.
+func MkfsVfat(path, label string) error {
+	exitCode, cmdErr := RunShellCommandNoSudo(fmt.Sprintf("mkfs.vfat -n %s %s", label, path))
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
+// CopyToVfatImage uses mcopy to copy a file into a FAT file system image, without mounting it.
+func CopyToVfatImage(image, source, target string) error {
+	exitCode, cmdErr := RunShellCommandNoSudo(fmt.Sprintf("mcopy -o -i %s %s ::%s", image, source, target))
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
+// Mount sudo mounts a rootfs file at a location, with comma separated mount options if not empty.
+func Mount(file, dir, options string) error {
+	command := fmt.Sprintf("mount %s %s", file, dir)
+	if options != "" {
+		command = fmt.Sprintf("mount -o %s %s %s", options, file, dir)
+	}
+	exitCode, cmdErr := RunShellCommandSudo(command)
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command finished with non-zero exit code")
+	}
+	return nil
+}
+
+// BindMount sudo bind mounts a file or directory at a location, which must exist.
+func BindMount(source, target string) error {
+	exitCode, cmdErr := RunCommand("sudo", "mount", "--bind", "--", source, target)
 	if cmdErr != nil {
 		return cmdErr
 	}
@@ -105,6 +205,31 @@ func Mount(file, dir string) error {
 	return nil
 }
 
+// MountPointsUnder returns the mount points at or below a directory, the most recent first so they can be unmounted in order.
+func MountPointsUnder(dir string) ([]string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir = filepath.Clean(dir)
+	mountPoints := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// the fifth field is the mount point
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := fields[4]
+		if mountPoint == dir || strings.HasPrefix(mountPoint, dir+"/") {
+			mountPoints = append([]string{mountPoint}, mountPoints...)
+		}
+	}
+	return mountPoints, scanner.Err()
+}
+
 // MoveFile moves file from source to destination.
 // os.Rename does not allow moving between drives
 // hence we have to rewrite the file.
@@ -151,6 +276,25 @@ func PathExists(path string) (bool, error) {
 	return true, nil
 }
 
+// RunCommandCombinedOutput runs a command without a shell and returns its combined stdout and stderr.
+func RunCommandCombinedOutput(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	return string(output), err
+}
+
+// RunCommand runs a command without a shell, so its arguments are never interpreted, and returns its exit code.
+func RunCommand(name string, args ...string) (int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return exitError.ExitCode(), exitError
+		}
+		return 1, fmt.Errorf("failed running command: %+v", err)
+	}
+	return 0, nil
+}
+
 // RunShellCommandNoSudo runs a shell command without sudo.
 func RunShellCommandNoSudo(command string) (int, error) {
 	return runShellCommand(command, false)
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// vmRoute is a runtime endpoint below /v1/vms/{id}/.
+// A "*" segment in the pattern matches any value and is passed to the handler as a parameter.
+type vmRoute struct {
+	method  string
+	pattern []string
+	handler func(w http.ResponseWriter, r *http.Request, vmID string, params []string)
+}
+
+var vmRoutes = []vmRoute{
+	{method: http.MethodPatch, pattern: []string{"balloon"}, handler: updateBalloonRequestHandler},
+	{method: http.MethodGet, pattern: []string{"balloon", "stats"}, handler: balloonStatsRequestHandler},
+	{method: http.MethodPatch, pattern: []string{"network-interfaces", "*"}, handler: updateNetworkInterfaceRequestHandler},
+	{method: http.MethodPatch, pattern: []string{"drives", "*"}, handler: updateDriveRequestHandler},
+	{method: http.MethodGet, pattern: []string{"metadata"}, handler: getMetadataRequestHandler},
+	{method: http.MethodPut, pattern: []string{"metadata"}, handler: putMetadataRequestHandler},
+	{method: http.MethodPatch, pattern: []string{"metadata"}, handler: patchMetadataRequestHandler},
+}
+
+// vmsRequestHandler dispatches the requests made to /v1/vms/{id}/... to the matching route.
+func vmsRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/vms/"), "/"), "/")
+	if len(segments) < 2 || segments[0] == "" {
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+		return
+	}
+
+	vmID, rest := segments[0], segments[1:]
+	methodNotAllowed := false
+
+	for _, route := range vmRoutes {
+		params, ok := matchVMRoute(route.pattern, rest)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			methodNotAllowed = true
+			continue
+		}
+		route.handler(w, r, vmID, params)
+		return
+	}
+
+	if methodNotAllowed {
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+		return
+	}
+
+	writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+}
+
+func matchVMRoute(pattern, segments []string) ([]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := []string{}
+	for i, p := range pattern {
+		if p == "*" {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params = append(params, segments[i])
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"open-fire/configs"
+	"open-fire/dtos/requests"
+	"open-fire/dtos/response"
+	"open-fire/managers"
+	"strings"
+)
+
+// volumesRequestHandler dispatches the requests made to /v1/volumes and /v1/volumes/{name}.
+func volumesRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Add("Content-Type", "application/json")
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/volumes"), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		listVolumesRequestHandler(w, r)
+	case name == "" && r.Method == http.MethodPost:
+		createVolumeRequestHandler(w, r)
+	case strings.Contains(name, "/"):
+		writeErrorResponse(w, 404, "not found: "+r.URL.Path)
+	case name != "" && r.Method == http.MethodGet:
+		getVolumeRequestHandler(w, r, name)
+	case name != "" && r.Method == http.MethodPut:
+		resizeVolumeRequestHandler(w, r, name)
+	case name != "" && r.Method == http.MethodDelete:
+		deleteVolumeRequestHandler(w, r, name)
+	default:
+		writeErrorResponse(w, 405, "method not allowed: "+r.Method)
+	}
+}
+
+func listVolumesRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	volumes, err := fcManager.ListVolumes()
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := response.ListVolumesResponse{
+		Volumes: []response.VolumeResponse{},
+	}
+	for _, volume := range volumes {
+		resp.Volumes = append(resp.Volumes, volumeResponse(volume))
+	}
+
+	writeJSONResponse(w, 200, &resp)
+}
+
+func createVolumeRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	var req requests.CreateVolumeRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	volumeConfig := configs.NewVolumeConfigFromRequest(&req)
+	if err := volumeConfig.Validate(); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	volume, err := fcManager.CreateVolume(volumeConfig)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := volumeResponse(volume)
+	writeJSONResponse(w, 201, &resp)
+}
+
+func getVolumeRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	volume, err := fcManager.GetVolume(name)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := volumeResponse(volume)
+	writeJSONResponse(w, 200, &resp)
+}
+
+func resizeVolumeRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	var req requests.ResizeVolumeRequest
+	if err := readJSONBody(r, &req); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+	if err := configs.ValidateVolumeSize(req.SizeMib); err != nil {
+		writeErrorResponse(w, 422, err.Error())
+		return
+	}
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	volume, err := fcManager.ResizeVolume(name, req.SizeMib)
+	if err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := volumeResponse(volume)
+	writeJSONResponse(w, 200, &resp)
+}
+
+func deleteVolumeRequestHandler(w http.ResponseWriter, r *http.Request, name string) {
+
+	fcManager := managers.CreateFCManagerInstance()
+
+	if err := fcManager.DeleteVolume(name); err != nil {
+		writeErrorResponse(w, managerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func volumeResponse(volume *managers.VolumeInfo) response.VolumeResponse {
+	return response.VolumeResponse{
+		Name:        volume.Name,
+		Path:        volume.Path,
+		SizeMib:     volume.SizeMib,
+		AttachedVMs: volume.AttachedVMs,
+		ReadWrite:   volume.ReadWrite,
+	}
+}